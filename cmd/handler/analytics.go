@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/analytics"
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+)
+
+// UnmetDemand handles a request to aggregate the searches that ended without
+// a booking. The period, the precision of the cells and the time zone of the
+// hours are given as query parameters, and all have defaults.
+func UnmetDemand(service analytics.UseCase) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+
+		q, err := parseDemandQuery(r, time.Now())
+		if err != nil {
+			return err
+		}
+
+		demand, err := service.UnmetDemand(r.Context(), q)
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		return json.NewEncoder(w).Encode(demand)
+	}
+}
+
+// parseDemandQuery reads the query parameters of a request for the unmet
+// demand. The period ends now by default.
+func parseDemandQuery(r *http.Request, now time.Time) (*analytics.DemandQuery, error) {
+	values := r.URL.Query()
+
+	q := &analytics.DemandQuery{
+		To:        now,
+		Precision: analytics.DefaultDemandPrecision,
+	}
+
+	if value := values.Get("to"); value != "" {
+		to, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, entity.NewValidationError("to must be an RFC 3339 date and time")
+		}
+
+		q.To = to
+	}
+
+	q.From = q.To.Add(-analytics.DefaultDemandPeriod)
+	if value := values.Get("from"); value != "" {
+		from, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, entity.NewValidationError("from must be an RFC 3339 date and time")
+		}
+
+		q.From = from
+	}
+
+	if value := values.Get("precision"); value != "" {
+		precision, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, entity.NewValidationError("precision must be a number")
+		}
+
+		q.Precision = precision
+	}
+
+	if value := values.Get("timezone"); value != "" {
+		location, err := time.LoadLocation(value)
+		if err != nil {
+			return nil, entity.NewValidationError("timezone must be an IANA time zone, like America/Toronto")
+		}
+
+		q.Location = location
+	}
+
+	return q, nil
+}
@@ -3,8 +3,9 @@ package handler
 import (
 	"context"
 	"net/http"
+	"strings"
 
-	"azure.com/ecovo/trip-search-service/cmd/middleware/auth"
+	"azure.com/ecovo/trip-search-service/pkg/auth"
 )
 
 // Auth validates a request's authorization header using the given validator
@@ -12,15 +13,20 @@ import (
 // authenticated user's information.
 //
 // The authenticated user's information placed in the request's context and can
-// be accessed by using the auth.FromContext utility function.
+// be accessed by using the auth.FromContext utility function. The bearer token
+// is placed there as well, so it can be forwarded to other services, and can be
+// accessed by using the auth.TokenFromContext utility function.
 func Auth(validator auth.Validator, next Handler) Handler {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		userInfo, err := validator.Validate(r.Header.Get("Authorization"))
+		authHeader := r.Header.Get("Authorization")
+
+		userInfo, err := validator.Validate(r.Context(), authHeader)
 		if err != nil {
 			return err
 		}
 
 		ctx := context.WithValue(r.Context(), auth.UserInfoContextKey, userInfo)
+		ctx = context.WithValue(ctx, auth.TokenContextKey, strings.TrimPrefix(authHeader, "Bearer "))
 		next.ServeHTTP(w, r.WithContext(ctx))
 
 		return nil
@@ -1,10 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
-	"azure.com/ecovo/trip-search-service/cmd/middleware/auth"
+	"azure.com/ecovo/trip-search-service/pkg/auth"
 	"azure.com/ecovo/trip-search-service/pkg/entity"
 	"azure.com/ecovo/trip-search-service/pkg/search"
 )
@@ -21,16 +22,27 @@ func (err Error) String() string {
 }
 
 // WrapError wraps the given error in an application error that can be handled
-// by a handler.
+// by a handler. The error is compared with the errors it may wrap, so that
+// the details added along the way do not change how it is handled.
 func WrapError(err error) *Error {
 	if err == nil {
 		return nil
-	} else if _, ok := err.(auth.UnauthorizedError); ok {
+	} else if errors.As(err, new(auth.UnauthorizedError)) {
 		return &Error{http.StatusUnauthorized, "unauthorized", err}
-	} else if _, ok := err.(search.NotFoundError); ok {
+	} else if errors.As(err, new(auth.ForbiddenError)) {
+		return &Error{http.StatusForbidden, "forbidden", err}
+	} else if errors.Is(err, search.ErrNotFound) {
 		return &Error{http.StatusNotFound, "search does not exist", err}
-	} else if _, ok := err.(entity.ValidationError); ok {
+	} else if errors.Is(err, search.ErrInvalidID) {
+		return &Error{http.StatusBadRequest, "invalid search ID", err}
+	} else if errors.As(err, new(entity.ValidationError)) {
 		return &Error{http.StatusBadRequest, err.Error(), err}
+	} else if errors.Is(err, search.ErrUnavailable) {
+		return &Error{
+			http.StatusServiceUnavailable,
+			"The service is temporarily unavailable. Please try again later.",
+			err,
+		}
 	} else {
 		return &Error{
 			http.StatusInternalServerError,
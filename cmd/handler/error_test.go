@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"azure.com/ecovo/trip-search-service/pkg/search"
+)
+
+func TestWrapError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"Should respond not found when the search does not exist", fmt.Errorf("search.MongoRepository: %w", search.ErrNotFound), http.StatusNotFound},
+		{"Should respond bad request when the ID is invalid", fmt.Errorf("search.MongoRepository: %w", search.ErrInvalidID), http.StatusBadRequest},
+		{"Should respond service unavailable when the database fails", fmt.Errorf("search.MongoRepository: failed (%w)", search.ErrUnavailable), http.StatusServiceUnavailable},
+		{"Should respond internal server error otherwise", errors.New("failed"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := WrapError(tt.err)
+			if err.Code != tt.code {
+				t.Errorf("expected code %d, got %d", tt.code, err.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerServeHTTP(t *testing.T) {
+	t.Run("Should log the error that caused the request to fail", func(t *testing.T) {
+		var buf bytes.Buffer
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+		h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+			return errors.New("connection refused")
+		})
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/search", nil))
+
+		if !strings.Contains(buf.String(), `error="connection refused"`) {
+			t.Errorf("expected the cause to be logged, got %q", buf.String())
+		}
+	})
+}
@@ -3,10 +3,10 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 
-	"azure.com/ecovo/trip-search-service/cmd/middleware/requestid"
+	"azure.com/ecovo/trip-search-service/pkg/requestid"
 )
 
 // A Handler represents a handler that can return an error.
@@ -17,7 +17,7 @@ func (handler Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if handlerErr != nil {
 		requestID, _ := requestid.FromContext(r.Context())
 
-		log.Printf("[Request ID=%s] error: %s", requestID, handlerErr)
+		slog.ErrorContext(r.Context(), "request failed", "code", handlerErr.Code, "error", handlerErr.Error)
 
 		type errorResponse struct {
 			*Error
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"azure.com/ecovo/trip-search-service/pkg/health"
+)
+
+// Liveness handles a request to check whether the service is alive. It always
+// responds with 200 OK as long as the service can handle requests, without
+// probing the dependencies, since restarting it would not bring a dependency
+// back.
+func Liveness() Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return writeReport(w, http.StatusOK, &health.Report{Status: health.StatusUp})
+	}
+}
+
+// Readiness handles a request to check whether the service is ready to receive
+// requests. It responds with 503 Service Unavailable when a dependency that is
+// not optional cannot be reached, or as soon as the service starts shutting
+// down.
+func Readiness(checker *health.Checker, shuttingDown func() bool) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if shuttingDown() {
+			return writeReport(w, http.StatusServiceUnavailable, &health.Report{Status: health.StatusShuttingDown})
+		}
+
+		report := checker.Run(r.Context())
+		if report.Status != health.StatusUp {
+			return writeReport(w, http.StatusServiceUnavailable, report)
+		}
+
+		return writeReport(w, http.StatusOK, report)
+	}
+}
+
+func writeReport(w http.ResponseWriter, code int, report *health.Report) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	return json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// AccessLog logs every request once it is handled, along with the status code
+// of the response and how long it took.
+func AccessLog(logger *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+			start := time.Now()
+
+			next.ServeHTTP(rw, r)
+
+			attrs := []interface{}{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"code", rw.code,
+				"duration", time.Since(start).String(),
+			}
+			if requestID := rw.Header().Get("X-Request-ID"); requestID != "" {
+				attrs = append(attrs, "requestId", requestID)
+			}
+
+			logger.InfoContext(r.Context(), "request handled", attrs...)
+		})
+	}
+}
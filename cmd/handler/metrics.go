@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/metrics"
+	"github.com/gorilla/mux"
+)
+
+// Metrics observes how long requests take to be handled, by method, route
+// template and status code. It must be used as a router middleware, so that
+// the route that matched the request is known.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		rw := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		start := time.Now()
+
+		next.ServeHTTP(rw, r)
+
+		metrics.HTTPRequestDuration.
+			WithLabelValues(r.Method, route, strconv.Itoa(rw.code)).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+// A statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (rw *statusRecorder) WriteHeader(code int) {
+	rw.code = code
+	rw.ResponseWriter.WriteHeader(code)
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"net/http"
 
-	"azure.com/ecovo/trip-search-service/cmd/middleware/requestid"
+	"azure.com/ecovo/trip-search-service/pkg/requestid"
 	"github.com/google/uuid"
 )
 
@@ -12,6 +12,7 @@ import (
 // present, and stores it in the request's context.
 //
 // If no request ID is present in the request's headers, it will be generated.
+// It is sent back in the response's headers.
 func RequestID(next Handler) Handler {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		requestID := r.Header.Get("X-Request-ID")
@@ -19,6 +20,8 @@ func RequestID(next Handler) Handler {
 			requestID = uuid.New().String()
 		}
 
+		w.Header().Set("X-Request-ID", requestID)
+
 		ctx := context.WithValue(r.Context(), requestid.RequestIDContextKey, requestID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 
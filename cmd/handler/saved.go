@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"azure.com/ecovo/trip-search-service/pkg/auth"
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/search"
+	"github.com/gorilla/mux"
+)
+
+// SaveSearch handles a request to save a search, to notify the authenticated
+// user when a matching trip is added.
+func SaveSearch(service search.SavedUseCase) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+
+		userInfo, err := auth.FromContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		var s *entity.SavedSearch
+		err = json.NewDecoder(r.Body).Decode(&s)
+		if err != nil {
+			return err
+		} else if s == nil {
+			return entity.NewValidationError("missing saved search")
+		}
+
+		// The saved search always belongs to the user who saves it.
+		s.ID = entity.NilID
+		s.UserID = userInfo.SubID
+
+		s, err = service.Create(r.Context(), s)
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusCreated)
+
+		return json.NewEncoder(w).Encode(s)
+	}
+}
+
+// GetSavedSearches handles a request to retrieve the saved searches of the
+// authenticated user.
+func GetSavedSearches(service search.SavedUseCase) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+
+		userInfo, err := auth.FromContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		searches, err := service.FindByUserID(r.Context(), userInfo.SubID)
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		return json.NewEncoder(w).Encode(searches)
+	}
+}
+
+// DeleteSavedSearch handles a request to delete one of the authenticated
+// user's saved searches by its unique identifier.
+func DeleteSavedSearch(service search.SavedUseCase) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		userInfo, err := auth.FromContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		id := entity.NewIDFromHex(mux.Vars(r)["id"])
+
+		err = service.Delete(r.Context(), userInfo.SubID, id)
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		return nil
+	}
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"azure.com/ecovo/trip-search-service/pkg/auth"
+)
+
+// RequireScopes ensures that the authenticated user was granted all of the
+// given scopes before letting the request through.
+//
+// It must be wrapped by the Auth handler, since it relies on the authenticated
+// user's information being present in the request's context.
+func RequireScopes(scopes []string, next Handler) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		userInfo, err := auth.FromContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		err = userInfo.Authorize(scopes...)
+		if err != nil {
+			return err
+		}
+
+		next.ServeHTTP(w, r)
+
+		return nil
+	}
+}
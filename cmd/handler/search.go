@@ -3,9 +3,11 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"azure.com/ecovo/trip-search-service/pkg/entity"
 	"azure.com/ecovo/trip-search-service/pkg/search"
+	"azure.com/ecovo/trip-search-service/pkg/webhook"
 	"github.com/gorilla/mux"
 )
 
@@ -20,16 +22,16 @@ func StartSearch(service search.UseCase) Handler {
 			return err
 		}
 
-		s, err = service.Create(s)
+		s, err = service.Create(r.Context(), s)
 		if err != nil {
 			return err
 		}
 
 		w.WriteHeader(http.StatusCreated)
 
-		err = json.NewEncoder(w).Encode(s)
+		err = json.NewEncoder(w).Encode(withoutCallbackSecret(s))
 		if err != nil {
-			_ = service.Delete(entity.ID(s.ID))
+			_ = service.Delete(r.Context(), entity.ID(s.ID), false)
 
 			return err
 		}
@@ -46,12 +48,12 @@ func GetSearchByID(service search.UseCase) Handler {
 		vars := mux.Vars(r)
 
 		id := entity.NewIDFromHex(vars["id"])
-		t, err := service.FindByID(id)
+		t, err := service.FindByID(r.Context(), id)
 		if err != nil {
 			return err
 		}
 
-		err = json.NewEncoder(w).Encode(t)
+		err = json.NewEncoder(w).Encode(withoutCallbackSecret(t))
 		if err != nil {
 			return err
 		}
@@ -62,15 +64,56 @@ func GetSearchByID(service search.UseCase) Handler {
 	}
 }
 
+// GetSearchDeliveries handles a request to retrieve the latest deliveries of
+// a search's results to its callback URL.
+func GetSearchDeliveries(service search.UseCase) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+
+		vars := mux.Vars(r)
+
+		id := entity.NewIDFromHex(vars["id"])
+		deliveries, err := service.Deliveries(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		if deliveries == nil {
+			deliveries = []*webhook.Delivery{}
+		}
+
+		return json.NewEncoder(w).Encode(deliveries)
+	}
+}
+
+// withoutCallbackSecret returns a copy of the search without its callback
+// secret, which is only ever given by the client.
+func withoutCallbackSecret(s *entity.Search) *entity.Search {
+	c := *s
+	c.CallbackSecret = ""
+
+	return &c
+}
+
 // StopSearch handles a request to stop searching for a trip by its unique
-// identifier.
+// identifier. The booked query parameter tells whether the rider stopped the
+// search because they booked a trip.
 func StopSearch(service search.UseCase) Handler {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		vars := mux.Vars(r)
 
 		id := entity.NewIDFromHex(vars["id"])
 
-		err := service.Delete(id)
+		booked := false
+		if value := r.URL.Query().Get("booked"); value != "" {
+			var err error
+			booked, err = strconv.ParseBool(value)
+			if err != nil {
+				return entity.NewValidationError("booked must be true or false")
+			}
+		}
+
+		err := service.Delete(r.Context(), id, booked)
 		if err != nil {
 			return err
 		}
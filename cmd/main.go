@@ -1,118 +1,320 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
+	"syscall"
 	"time"
+	// The container has no time zone database, and the time zones are needed
+	// to aggregate the demand by hour of the day.
+	_ "time/tzdata"
 
 	"azure.com/ecovo/trip-search-service/cmd/handler"
-	"azure.com/ecovo/trip-search-service/cmd/middleware/auth"
+	"azure.com/ecovo/trip-search-service/pkg/analytics"
+	"azure.com/ecovo/trip-search-service/pkg/auth"
+	"azure.com/ecovo/trip-search-service/pkg/config"
 	"azure.com/ecovo/trip-search-service/pkg/db"
+	"azure.com/ecovo/trip-search-service/pkg/health"
+	"azure.com/ecovo/trip-search-service/pkg/lifecycle"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/notification"
 	"azure.com/ecovo/trip-search-service/pkg/pubsub"
 	"azure.com/ecovo/trip-search-service/pkg/pubsub/subscription"
 	"azure.com/ecovo/trip-search-service/pkg/route"
 	"azure.com/ecovo/trip-search-service/pkg/search"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
 	"azure.com/ecovo/trip-search-service/pkg/trip"
+	"azure.com/ecovo/trip-search-service/pkg/webhook"
 	"github.com/ably/ably-go/ably"
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"googlemaps.github.io/maps"
 )
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML configuration file")
+	flag.Usage = usage
+	flag.Parse()
+
+	// Commands only need the database, so the other dependencies do not have
+	// to be configured to run them.
+	load := config.Load
+	if flag.NArg() > 0 {
+		load = config.LoadDB
 	}
 
-	authConfig := auth.Config{
-		Domain: os.Getenv("AUTH_DOMAIN")}
-	authValidator, err := auth.NewTokenValidator(&authConfig)
+	conf, err := load(*configFile)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+
+	logLevel, _ := logging.ParseLevel(conf.Log.Level)
+	logger := logging.New(os.Stdout, logLevel)
+	// The handlers and the standard library's log package use the default
+	// logger, since they cannot be given one.
+	slog.SetDefault(logger)
+
+	var database *db.DB
+
+	if !conf.DB.InMemory {
+		database, err = db.New(conf.DBConfig())
+		if err != nil {
+			fatal(err)
+		}
 	}
 
-	dbConnectionTimeout, err := time.ParseDuration(os.Getenv("DB_CONNECTION_TIMEOUT") + "s")
+	if flag.NArg() > 0 {
+		err := runCommand(database, flag.Args(), logger)
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	tracingProvider, err := tracing.NewProvider(conf.TracingConfig())
 	if err != nil {
-		dbConnectionTimeout = db.DefaultConnectionTimeout
+		fatal(err)
 	}
-	dbConfig := db.Config{
-		Host:              os.Getenv("DB_HOST"),
-		Username:          os.Getenv("DB_USERNAME"),
-		Password:          os.Getenv("DB_PASSWORD"),
-		Name:              os.Getenv("DB_NAME"),
-		ConnectionTimeout: dbConnectionTimeout}
-	db, err := db.New(&dbConfig)
+
+	authValidator, err := auth.NewTokenValidator(conf.AuthConfig())
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
-	ablyClient, err := ably.NewRealtimeClient(ably.NewClientOptions(os.Getenv("ABLY_API_KEY")))
+	if database != nil {
+		err = migrate(database, logger)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	ablyClient, err := ably.NewRealtimeClient(conf.AblyOptions())
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
 	ablyPubSubRepository, err := subscription.NewAblyRepository(ablyClient)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 	pubSubService := pubsub.NewService(ablyPubSubRepository)
 
 	var tripRepository trip.Repository
 
-	sendMocks := false
-	if os.Getenv("SEND_MOCKS") != "" {
-		sendMocks, err = strconv.ParseBool(os.Getenv("SEND_MOCKS"))
-		if err != nil {
-			log.Fatal("SEND_MOCKS env variable must be true or false")
-		}
-	}
-
-	if sendMocks {
+	if conf.TripService.SendMocks {
 		tripRepository, err = trip.NewMockRepository()
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 	} else {
-		tripRepository, err = trip.NewRestRepository(os.Getenv("TRIP_SERVICE_DOMAIN"), os.Getenv("TRIP_SERVICE_AUTH"))
+		tripCredentials, err := conf.TripCredentials()
+		if err != nil {
+			fatal(err)
+		}
+
+		tripRepository, err = trip.NewRestRepository(conf.TripService.Domain, tripCredentials, conf.TripClientConfig(), logger)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 	}
 
 	tripUseCase := trip.NewService(tripRepository)
 
-	mapsClient, err := maps.NewClient(maps.WithAPIKey(os.Getenv("GOOGLE_MAPS_API_KEY")))
+	mapsClient, err := maps.NewClient(conf.GoogleMapsOptions()...)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
 	routeRepository, err := route.NewGoogleMapsRepository(mapsClient)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 	routeUseCase := route.NewService(routeRepository)
 
-	searchRepository, err := search.NewMongoRepository(db.Searches)
+	var searchRepository search.Repository
+	var savedSearchRepository search.SavedRepository
+	var analyticsRepository analytics.Repository
+	var deliveryLog webhook.DeliveryLog
+
+	if database == nil {
+		searchRepository, err = search.NewMemoryRepository()
+		if err != nil {
+			fatal(err)
+		}
+
+		savedSearchRepository, err = search.NewSavedMemoryRepository()
+		if err != nil {
+			fatal(err)
+		}
+
+		deliveryLog, err = webhook.NewMemoryDeliveryLog()
+		if err != nil {
+			fatal(err)
+		}
+
+		analyticsRepository, err = analytics.NewMemoryRepository()
+	} else {
+		searchRepository, err = search.NewMongoRepository(database.Searches)
+		if err != nil {
+			fatal(err)
+		}
+
+		savedSearchRepository, err = search.NewSavedMongoRepository(database.SavedSearches)
+		if err != nil {
+			fatal(err)
+		}
+
+		deliveryLog, err = webhook.NewMongoDeliveryLog(database.WebhookDeliveries)
+		if err != nil {
+			fatal(err)
+		}
+
+		analyticsRepository, err = analytics.NewMongoRepository(database.SearchRecords)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	analyticsUseCase := analytics.NewService(analyticsRepository)
+
+	webhookClient, err := webhook.NewClient(conf.WebhookClientConfig())
+	if err != nil {
+		fatal(err)
+	}
+
+	webhookDispatcher, err := webhook.NewDispatcher(webhookClient, deliveryLog, conf.Webhook.MaxConcurrentDeliveries, logger)
+	if err != nil {
+		fatal(err)
+	}
+
+	notifier, err := notification.NewPubSubNotifier(pubSubService)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
-	searchUseCase, err := search.NewService(searchRepository, pubSubService, tripUseCase, routeUseCase)
+
+	if conf.Notification.WebhookURL != "" {
+		notificationClient, err := webhook.NewClient(conf.NotificationClientConfig())
+		if err != nil {
+			fatal(err)
+		}
+
+		webhookNotifier, err := notification.NewWebhookNotifier(notificationClient, conf.Notification.WebhookURL, conf.Notification.WebhookSecret)
+		if err != nil {
+			fatal(err)
+		}
+
+		notifier = notification.NewMultiNotifier(notifier, webhookNotifier)
+	}
+
+	savedSearchUseCase, err := search.NewSavedService(savedSearchRepository, notifier, logger)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
+	workerPool, err := search.NewPool(conf.WorkerPool.Concurrency, conf.WorkerPool.QueueSize)
+	if err != nil {
+		fatal(err)
+	}
+
+	searchUseCase, err := search.NewService(searchRepository, pubSubService, tripUseCase, routeUseCase, analyticsUseCase, savedSearchUseCase, webhookDispatcher, workerPool, conf.LeaseConfig(), logger)
+	if err != nil {
+		fatal(err)
+	}
+
+	healthChecker, err := health.NewChecker(time.Duration(conf.Server.HealthCheckTimeout))
+	if err != nil {
+		fatal(err)
+	}
+	if database != nil {
+		healthChecker.Register("database", database.Ping)
+	}
+	healthChecker.Register("ably", ablyPubSubRepository.Ping)
+	healthChecker.RegisterOptional("tripService", tripRepository.Ping)
+	healthChecker.RegisterOptional("routeProvider", routeRepository.Ping)
+
+	lc := lifecycle.NewManager(logger)
+
 	r := mux.NewRouter()
+	r.Use(handler.Tracing, handler.Metrics, handler.AccessLog(logger))
 
-	r.Handle("/search/{id}", handler.RequestID(handler.Auth(authValidator, handler.GetSearchByID(searchUseCase)))).
+	r.Handle("/metrics", promhttp.Handler()).
 		Methods("GET")
-	r.Handle("/search", handler.RequestID(handler.Auth(authValidator, handler.StartSearch(searchUseCase)))).
+
+	r.Handle("/healthz", handler.Liveness()).
+		Methods("GET")
+	r.Handle("/readyz", handler.Readiness(healthChecker, lc.ShuttingDown)).
+		Methods("GET")
+
+	r.Handle("/search/{id}", handler.RequestID(handler.Auth(authValidator, handler.RequireScopes([]string{auth.ScopeSearchRead}, handler.GetSearchByID(searchUseCase))))).
+		Methods("GET")
+	r.Handle("/search", handler.RequestID(handler.Auth(authValidator, handler.RequireScopes([]string{auth.ScopeSearchCreate}, handler.StartSearch(searchUseCase))))).
+		Methods("POST").
+		HeadersRegexp("Content-Type", "application/(json|json; charset=utf8)")
+	r.Handle("/search/{id}", handler.RequestID(handler.Auth(authValidator, handler.RequireScopes([]string{auth.ScopeSearchDelete}, handler.StopSearch(searchUseCase))))).
+		Methods("DELETE")
+	r.Handle("/search/{id}/deliveries", handler.RequestID(handler.Auth(authValidator, handler.RequireScopes([]string{auth.ScopeSearchRead}, handler.GetSearchDeliveries(searchUseCase))))).
+		Methods("GET")
+
+	r.Handle("/saved-search", handler.RequestID(handler.Auth(authValidator, handler.RequireScopes([]string{auth.ScopeSearchCreate}, handler.SaveSearch(savedSearchUseCase))))).
 		Methods("POST").
 		HeadersRegexp("Content-Type", "application/(json|json; charset=utf8)")
-	r.Handle("/search/{id}", handler.RequestID(handler.Auth(authValidator, handler.StopSearch(searchUseCase)))).
+	r.Handle("/saved-search", handler.RequestID(handler.Auth(authValidator, handler.RequireScopes([]string{auth.ScopeSearchRead}, handler.GetSavedSearches(savedSearchUseCase))))).
+		Methods("GET")
+	r.Handle("/saved-search/{id}", handler.RequestID(handler.Auth(authValidator, handler.RequireScopes([]string{auth.ScopeSearchDelete}, handler.DeleteSavedSearch(savedSearchUseCase))))).
 		Methods("DELETE")
 
-	log.Fatal(http.ListenAndServe(":"+port, handlers.LoggingHandler(os.Stdout, r)))
+	r.Handle("/admin/demand", handler.RequestID(handler.Auth(authValidator, handler.RequireScopes([]string{auth.ScopeSearchAdmin}, handler.UnmetDemand(analyticsUseCase))))).
+		Methods("GET")
+
+	server := &http.Server{
+		Addr:    ":" + conf.Server.Port,
+		Handler: r}
+
+	go func() {
+		err := server.ListenAndServe()
+		if err != http.ErrServerClosed {
+			fatal(err)
+		}
+	}()
+
+	// The components are shut down in order, from the ones that receive work
+	// to the ones they depend on to get it done. The service keeps handling
+	// requests for a while once it reports that it is not ready, so that the
+	// platform has time to stop sending it new ones.
+	lc.OnShutdown("readiness", func(ctx context.Context) error {
+		select {
+		case <-time.After(time.Duration(conf.Server.ReadinessDrainDelay)):
+		case <-ctx.Done():
+		}
+		return nil
+	})
+	lc.OnShutdown("HTTP server", server.Shutdown)
+	lc.OnShutdown("searches", searchUseCase.Shutdown)
+	lc.OnShutdown("Ably client", func(ctx context.Context) error {
+		return ablyClient.Close()
+	})
+	if database != nil {
+		lc.OnShutdown("database", database.Disconnect)
+	}
+	lc.OnShutdown("tracing", tracingProvider.Shutdown)
+
+	sig := lc.WaitForSignal(syscall.SIGTERM, os.Interrupt)
+	logger.Info("shutting down", "signal", sig.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(conf.Server.ShutdownTimeout))
+	defer cancel()
+
+	err = lc.Shutdown(ctx)
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// fatal logs the error and exits.
+func fatal(v interface{}) {
+	slog.Error(fmt.Sprint(v))
+	os.Exit(1)
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/db"
+)
+
+// usage prints how to start the service or run one of its commands.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Without a command, the service is started.")
+	fmt.Fprintln(out, "\nCommands:")
+	fmt.Fprintln(out, "  migrate          apply the pending database migrations")
+	fmt.Fprintln(out, "  migrate status   list the database migrations and whether they were applied")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// runCommand runs the command given on the command line instead of starting
+// the service.
+func runCommand(database *db.DB, args []string, logger *slog.Logger) error {
+	if database == nil {
+		return errors.New("commands require a database, but searches are kept in memory")
+	}
+	defer database.Disconnect(context.Background())
+
+	switch {
+	case len(args) == 1 && args[0] == "migrate":
+		return migrate(database, logger)
+	case len(args) == 2 && args[0] == "migrate" && args[1] == "status":
+		return printMigrationStates(database)
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command \"%s\"", args[0])
+	}
+}
+
+// migrate applies the pending database migrations.
+func migrate(database *db.DB, logger *slog.Logger) error {
+	ctx := context.Background()
+
+	applied, err := database.Migrate(ctx)
+	for _, m := range applied {
+		logger.InfoContext(ctx, "applied migration", "version", m.Version, "description", m.Description)
+	}
+
+	return err
+}
+
+// printMigrationStates lists the database migrations and whether they were
+// applied.
+func printMigrationStates(database *db.DB) error {
+	states, err := database.MigrationStates(context.Background())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED AT\tDESCRIPTION")
+	for _, s := range states {
+		appliedAt := "pending"
+		if !s.Pending() {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, appliedAt, s.Description)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,166 @@
+// Package analytics keeps a record of every search once it ends, and
+// aggregates the records to find where and when riders search for trips
+// without booking one.
+//
+// Records are only ever appended. They keep the search's filters, so the
+// demand can be aggregated in ways that were not planned for when it was
+// recorded.
+package analytics
+
+import (
+	"fmt"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/geohash"
+)
+
+// A Record describes a search that ended.
+type Record struct {
+	SearchID entity.ID
+	Filters  *entity.Filters
+
+	// StartedAt represents when the search was created.
+	StartedAt time.Time
+
+	// EndedAt represents when the search was stopped.
+	EndedAt time.Time
+
+	// Results represents how many distinct trips were found for the search.
+	Results int
+
+	// Booked represents whether the rider stopped the search because they
+	// booked a trip.
+	Booked bool
+}
+
+// Lifetime returns how long the search ran.
+func (r *Record) Lifetime() time.Duration {
+	return r.EndedAt.Sub(r.StartedAt)
+}
+
+// TravelAt returns when the rider wanted to travel, either the time they
+// wanted to leave at or to arrive by.
+func (r *Record) TravelAt() time.Time {
+	return r.Filters.TravelAt()
+}
+
+func (r *Record) validate() error {
+	if r.SearchID.IsZero() {
+		return fmt.Errorf("missing search ID")
+	}
+
+	if r.Filters == nil || r.Filters.Source == nil || r.Filters.Destination == nil {
+		return fmt.Errorf("missing source or destination")
+	}
+
+	return nil
+}
+
+// cellPrecision represents the precision of the geohash cells the source and
+// destination of records are stored in. Demand can be aggregated in cells of
+// this precision or less.
+const cellPrecision = 6
+
+// cells returns the geohash cells of the record's source and destination.
+func (r *Record) cells() (string, string) {
+	s, d := r.Filters.Source, r.Filters.Destination
+	return geohash.Encode(s.Latitude, s.Longitude, cellPrecision), geohash.Encode(d.Latitude, d.Longitude, cellPrecision)
+}
+
+// Demand represents the searches that ended without a booking for trips
+// between two cells, at a given hour of the day.
+type Demand struct {
+	// SourceCell represents the geohash cell where the riders wanted to
+	// leave from.
+	SourceCell string `json:"sourceCell"`
+
+	// DestinationCell represents the geohash cell where the riders wanted to
+	// go.
+	DestinationCell string `json:"destinationCell"`
+
+	// Hour represents the hour of the day, from 0 to 23, at which the riders
+	// wanted to travel.
+	Hour int `json:"hour"`
+
+	// Searches represents how many searches ended without a booking.
+	Searches int `json:"searches"`
+
+	// WithoutResults represents how many of those searches found no trip at
+	// all.
+	WithoutResults int `json:"withoutResults"`
+}
+
+// lessDemand orders demand from the most searched, and then by cells and
+// hour, so that the order does not depend on how it was aggregated.
+func lessDemand(a, b *Demand) bool {
+	if a.Searches != b.Searches {
+		return a.Searches > b.Searches
+	}
+
+	if a.SourceCell != b.SourceCell {
+		return a.SourceCell < b.SourceCell
+	}
+
+	if a.DestinationCell != b.DestinationCell {
+		return a.DestinationCell < b.DestinationCell
+	}
+
+	return a.Hour < b.Hour
+}
+
+// A DemandQuery specifies how to aggregate the demand.
+type DemandQuery struct {
+	// From and To specify the period in which the searches ended. From is
+	// included and To is not.
+	From time.Time
+	To   time.Time
+
+	// Precision specifies the precision of the geohash cells, from 1 to
+	// MaxDemandPrecision. Higher precisions give smaller cells.
+	Precision int
+
+	// Location specifies the time zone of the hours of the day.
+	//
+	// A nil location means UTC.
+	Location *time.Location
+}
+
+const (
+	// DefaultDemandPrecision represents the default precision of the cells,
+	// which are about 40 km by 20 km.
+	DefaultDemandPrecision = 4
+
+	// MaxDemandPrecision represents the highest precision of the cells,
+	// which are about 1.2 km by 0.6 km.
+	MaxDemandPrecision = cellPrecision
+
+	// DefaultDemandPeriod represents the default period in which the
+	// searches ended.
+	DefaultDemandPeriod = 7 * 24 * time.Hour
+)
+
+// Validate validates that the query's fields are filled out correctly.
+func (q *DemandQuery) Validate() error {
+	if q.From.IsZero() || q.To.IsZero() {
+		return entity.NewValidationError("missing period")
+	}
+
+	if !q.From.Before(q.To) {
+		return entity.NewValidationError("period must end after it starts")
+	}
+
+	if q.Precision < geohash.MinPrecision || q.Precision > MaxDemandPrecision {
+		return entity.NewValidationError(fmt.Sprintf("precision must be between %d and %d", geohash.MinPrecision, MaxDemandPrecision))
+	}
+
+	return nil
+}
+
+func (q *DemandQuery) location() *time.Location {
+	if q.Location == nil {
+		return time.UTC
+	}
+
+	return q.Location
+}
@@ -0,0 +1,91 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// A MemoryRepository is a repository that keeps records in memory. It
+// aggregates them like a MongoRepository, so that it can replace it in tests
+// and when running the service locally.
+//
+// It is safe for concurrent use, but the records are lost when the service
+// stops.
+type MemoryRepository struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewMemoryRepository creates an empty in-memory analytics repository.
+func NewMemoryRepository() (Repository, error) {
+	return &MemoryRepository{}, nil
+}
+
+// Append stores a copy of the record.
+func (r *MemoryRepository) Append(ctx context.Context, record *Record) error {
+	if record == nil {
+		return fmt.Errorf("analytics.MemoryRepository: failed to append record (record is nil)")
+	}
+
+	c := *record
+	if c.Filters != nil {
+		f := *c.Filters
+		c.Filters = &f
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = append(r.records, c)
+
+	return nil
+}
+
+// UnmetDemand aggregates the records of the searches that ended without a
+// booking, as specified by the query, from the most searched.
+func (r *MemoryRepository) UnmetDemand(ctx context.Context, q *DemandQuery) ([]*Demand, error) {
+	type key struct {
+		source      string
+		destination string
+		hour        int
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	groups := make(map[key]*Demand)
+	for i := range r.records {
+		record := &r.records[i]
+		if record.Booked || record.EndedAt.Before(q.From) || !record.EndedAt.Before(q.To) {
+			continue
+		}
+
+		source, destination := record.cells()
+		k := key{
+			source:      source[:q.Precision],
+			destination: destination[:q.Precision],
+			hour:        record.TravelAt().In(q.location()).Hour(),
+		}
+
+		d, ok := groups[k]
+		if !ok {
+			d = &Demand{SourceCell: k.source, DestinationCell: k.destination, Hour: k.hour}
+			groups[k] = d
+		}
+
+		d.Searches++
+		if record.Results == 0 {
+			d.WithoutResults++
+		}
+	}
+
+	demand := make([]*Demand, 0, len(groups))
+	for _, d := range groups {
+		demand = append(demand, d)
+	}
+	sort.Slice(demand, func(i, j int) bool { return lessDemand(demand[i], demand[j]) })
+
+	return demand, nil
+}
@@ -0,0 +1,184 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/mongodb/mongo-go-driver/bson/primitive"
+	"github.com/mongodb/mongo-go-driver/mongo"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("azure.com/ecovo/trip-search-service/pkg/analytics")
+
+// A MongoRepository is a repository that appends records to a MongoDB
+// collection and aggregates them there.
+type MongoRepository struct {
+	collection *mongo.Collection
+}
+
+type document struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty"`
+	SearchID        string             `bson:"searchId"`
+	Source          *entity.Point      `bson:"source"`
+	Destination     *entity.Point      `bson:"destination"`
+	SourceCell      string             `bson:"sourceCell"`
+	DestinationCell string             `bson:"destinationCell"`
+	Seats           *int               `bson:"seats,omitempty"`
+	LeaveAt         time.Time          `bson:"leaveAt,omitempty"`
+	ArriveBy        time.Time          `bson:"arriveBy,omitempty"`
+	TravelAt        time.Time          `bson:"travelAt"`
+	StartedAt       time.Time          `bson:"startedAt"`
+	EndedAt         time.Time          `bson:"endedAt"`
+	Results         int                `bson:"results"`
+	Booked          bool               `bson:"booked"`
+}
+
+func newDocumentFromRecord(r *Record) *document {
+	sourceCell, destinationCell := r.cells()
+
+	return &document{
+		SearchID:        r.SearchID.Hex(),
+		Source:          r.Filters.Source,
+		Destination:     r.Filters.Destination,
+		SourceCell:      sourceCell,
+		DestinationCell: destinationCell,
+		Seats:           r.Filters.Seats,
+		LeaveAt:         r.Filters.LeaveAt,
+		ArriveBy:        r.Filters.ArriveBy,
+		TravelAt:        r.TravelAt(),
+		StartedAt:       r.StartedAt,
+		EndedAt:         r.EndedAt,
+		Results:         r.Results,
+		Booked:          r.Booked,
+	}
+}
+
+// operationTimeout represents how long to wait for the database server to
+// complete an operation before giving up on it. Aggregations go through every
+// record of the period, so they are given more time.
+const (
+	operationTimeout   = 5 * time.Second
+	aggregationTimeout = 30 * time.Second
+)
+
+// startOperation gives the database server the timeout to complete an
+// operation, and traces it. The returned function must be called with the
+// operation's error once it is done.
+func (r *MongoRepository) startOperation(ctx context.Context, operation string, timeout time.Duration) (context.Context, func(error)) {
+	ctx, span := tracer.Start(ctx, "mongo."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemMongoDB,
+		semconv.DBCollectionName(r.collection.Name()),
+		semconv.DBOperationName(operation),
+	))
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	return ctx, func(err error) {
+		cancel()
+		tracing.End(span, err)
+	}
+}
+
+// NewMongoRepository creates an analytics repository for a MongoDB
+// collection.
+func NewMongoRepository(collection *mongo.Collection) (Repository, error) {
+	if collection == nil {
+		return nil, fmt.Errorf("analytics.MongoRepository: collection is nil")
+	}
+
+	return &MongoRepository{collection}, nil
+}
+
+// Append stores the record in the database.
+func (r *MongoRepository) Append(ctx context.Context, record *Record) (err error) {
+	if record == nil {
+		return fmt.Errorf("analytics.MongoRepository: failed to append record (record is nil)")
+	}
+
+	ctx, done := r.startOperation(ctx, "insertOne", operationTimeout)
+	defer func() { done(err) }()
+
+	_, err = r.collection.InsertOne(ctx, newDocumentFromRecord(record))
+	if err != nil {
+		return fmt.Errorf("analytics.MongoRepository: failed to append record of search \"%s\" (%s)", record.SearchID, err)
+	}
+
+	return nil
+}
+
+// UnmetDemand aggregates the records of the searches that ended without a
+// booking, as specified by the query, from the most searched.
+func (r *MongoRepository) UnmetDemand(ctx context.Context, q *DemandQuery) (_ []*Demand, err error) {
+	ctx, done := r.startOperation(ctx, "aggregate", aggregationTimeout)
+	defer func() { done(err) }()
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: bson.D{
+			{Key: "booked", Value: false},
+			{Key: "endedAt", Value: bson.D{{Key: "$gte", Value: q.From}, {Key: "$lt", Value: q.To}}},
+		}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "source", Value: bson.D{{Key: "$substrBytes", Value: bson.A{"$sourceCell", 0, q.Precision}}}},
+				{Key: "destination", Value: bson.D{{Key: "$substrBytes", Value: bson.A{"$destinationCell", 0, q.Precision}}}},
+				{Key: "hour", Value: bson.D{{Key: "$hour", Value: bson.D{
+					{Key: "date", Value: "$travelAt"},
+					{Key: "timezone", Value: q.location().String()},
+				}}}},
+			}},
+			{Key: "searches", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "withoutResults", Value: bson.D{{Key: "$sum", Value: bson.D{
+				{Key: "$cond", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{"$results", 0}}}, 1, 0}},
+			}}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{
+			{Key: "searches", Value: -1},
+			{Key: "_id.source", Value: 1},
+			{Key: "_id.destination", Value: 1},
+			{Key: "_id.hour", Value: 1},
+		}}},
+	}
+
+	cur, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("analytics.MongoRepository: failed to aggregate unmet demand (%s)", err)
+	}
+	defer cur.Close(ctx)
+
+	demand := []*Demand{}
+	for cur.Next(ctx) {
+		var g struct {
+			ID struct {
+				Source      string `bson:"source"`
+				Destination string `bson:"destination"`
+				Hour        int    `bson:"hour"`
+			} `bson:"_id"`
+			Searches       int `bson:"searches"`
+			WithoutResults int `bson:"withoutResults"`
+		}
+		err := cur.Decode(&g)
+		if err != nil {
+			return nil, fmt.Errorf("analytics.MongoRepository: failed to decode unmet demand (%s)", err)
+		}
+
+		demand = append(demand, &Demand{
+			SourceCell:      g.ID.Source,
+			DestinationCell: g.ID.Destination,
+			Hour:            g.ID.Hour,
+			Searches:        g.Searches,
+			WithoutResults:  g.WithoutResults,
+		})
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("analytics.MongoRepository: failed to aggregate unmet demand (%s)", err)
+	}
+
+	return demand, nil
+}
@@ -0,0 +1,13 @@
+package analytics
+
+import "context"
+
+// Repository is an interface representing the ability to append records to,
+// and aggregate them from, a database.
+type Repository interface {
+	Append(ctx context.Context, r *Record) error
+
+	// UnmetDemand aggregates the records of the searches that ended without
+	// a booking, as specified by the query, from the most searched.
+	UnmetDemand(ctx context.Context, q *DemandQuery) ([]*Demand, error)
+}
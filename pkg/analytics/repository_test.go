@@ -0,0 +1,154 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"github.com/mongodb/mongo-go-driver/bson/primitive"
+	"github.com/mongodb/mongo-go-driver/mongo"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) Repository {
+		r, err := NewMemoryRepository()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	})
+}
+
+// TestMongoRepository runs against the MongoDB server given by TEST_MONGO_URI,
+// in a database created for the test, and is skipped when it is not defined.
+func TestMongoRepository(t *testing.T) {
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not defined")
+	}
+
+	client, err := mongo.NewClient(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+
+	testRepository(t, func(t *testing.T) Repository {
+		db := client.Database(fmt.Sprintf("trip_search_test_%s", primitive.NewObjectID().Hex()))
+		t.Cleanup(func() {
+			db.Drop(context.Background())
+		})
+
+		r, err := NewMongoRepository(db.Collection("searchRecords"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	})
+}
+
+// testRepository checks that a repository behaves as the Repository interface
+// describes. newRepository must return an empty repository every time.
+func testRepository(t *testing.T, newRepository func(t *testing.T) Repository) {
+	ctx := context.Background()
+
+	montreal := &entity.Point{Latitude: 45.5017, Longitude: -73.5673}
+	plateau := &entity.Point{Latitude: 45.52, Longitude: -73.58}
+	quebec := &entity.Point{Latitude: 46.8139, Longitude: -71.2080}
+	endedAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	record := func(source, destination *entity.Point, leaveAt time.Time, results int, booked bool) *Record {
+		return &Record{
+			SearchID:  entity.ID(primitive.NewObjectID().Hex()),
+			Filters:   &entity.Filters{Source: source, Destination: destination, LeaveAt: leaveAt},
+			StartedAt: endedAt.Add(-time.Hour),
+			EndedAt:   endedAt,
+			Results:   results,
+			Booked:    booked,
+		}
+	}
+
+	t.Run("Should aggregate the searches that ended without a booking", func(t *testing.T) {
+		r := newRepository(t)
+		friday := time.Date(2026, 10, 23, 21, 30, 0, 0, time.UTC)
+
+		for _, rec := range []*Record{
+			record(montreal, quebec, friday, 0, false),
+			record(plateau, quebec, friday, 3, false),
+			record(montreal, quebec, friday, 2, true),
+			record(quebec, montreal, friday.Add(-10*time.Hour), 0, false),
+		} {
+			err := r.Append(ctx, rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		outside := record(montreal, quebec, friday, 0, false)
+		outside.EndedAt = endedAt.Add(-48 * time.Hour)
+		err := r.Append(ctx, outside)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		demand, err := r.UnmetDemand(ctx, &DemandQuery{
+			From:      endedAt.Add(-24 * time.Hour),
+			To:        endedAt.Add(time.Second),
+			Precision: 4,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []Demand{
+			{SourceCell: "f25d", DestinationCell: "f2m6", Hour: 21, Searches: 2, WithoutResults: 1},
+			{SourceCell: "f2m6", DestinationCell: "f25d", Hour: 11, Searches: 1, WithoutResults: 1},
+		}
+		if len(demand) != len(expected) {
+			t.Fatalf("expected %d groups, got %d", len(expected), len(demand))
+		}
+		for i := range expected {
+			if *demand[i] != expected[i] {
+				t.Errorf("expected %+v, got %+v", expected[i], *demand[i])
+			}
+		}
+	})
+
+	t.Run("Should use the hours of the given time zone", func(t *testing.T) {
+		r := newRepository(t)
+
+		err := r.Append(ctx, record(montreal, quebec, time.Date(2026, 10, 23, 21, 30, 0, 0, time.UTC), 0, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		toronto, err := time.LoadLocation("America/Toronto")
+		if err != nil {
+			t.Skip(err)
+		}
+
+		demand, err := r.UnmetDemand(ctx, &DemandQuery{
+			From:      endedAt.Add(-time.Hour),
+			To:        endedAt.Add(time.Hour),
+			Precision: 2,
+			Location:  toronto,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(demand) != 1 || demand[0].Hour != 17 {
+			t.Errorf("expected one group at 17h, got %v", demand)
+		}
+	})
+}
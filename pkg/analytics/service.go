@@ -0,0 +1,53 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+)
+
+// UseCase is an interface representing the ability to handle the business
+// logic that involves analytics.
+type UseCase interface {
+	Record(ctx context.Context, r *Record) error
+	UnmetDemand(ctx context.Context, q *DemandQuery) ([]*Demand, error)
+}
+
+// A Service handles the business logic related to analytics.
+type Service struct {
+	repo Repository
+}
+
+// NewService creates an analytics service to record searches and aggregate
+// them through a repository.
+func NewService(repo Repository) UseCase {
+	return &Service{repo}
+}
+
+// Record appends the record of a search that ended.
+func (s *Service) Record(ctx context.Context, r *Record) error {
+	if r == nil {
+		return fmt.Errorf("analytics.Service: record is nil")
+	}
+
+	err := r.validate()
+	if err != nil {
+		return fmt.Errorf("analytics.Service: invalid record (%s)", err)
+	}
+
+	return s.repo.Append(ctx, r)
+}
+
+// UnmetDemand validates the query and aggregates the searches that ended
+// without a booking.
+func (s *Service) UnmetDemand(ctx context.Context, q *DemandQuery) ([]*Demand, error) {
+	if q == nil {
+		return nil, fmt.Errorf("analytics.Service: query is nil")
+	}
+
+	err := q.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.UnmetDemand(ctx, q)
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// UserInfo contains a user's basic information extracted from an access token.
+type UserInfo struct {
+	SubID     string   `json:"sub,omitempty"`
+	FirstName string   `json:"given_name"`
+	LastName  string   `json:"family_name"`
+	Picture   string   `json:"picture"`
+	Email     string   `json:"email"`
+	Scopes    []string `json:"-"`
+}
+
+const (
+	// ScopeSearchCreate represents the permission to start a search.
+	ScopeSearchCreate = "search:create"
+
+	// ScopeSearchRead represents the permission to retrieve a search.
+	ScopeSearchRead = "search:read"
+
+	// ScopeSearchDelete represents the permission to stop a search.
+	ScopeSearchDelete = "search:delete"
+
+	// ScopeSearchAdmin represents the permission to do anything with
+	// searches. It implies every other search scope.
+	ScopeSearchAdmin = "search:admin"
+)
+
+// HasScopes returns whether the user was granted all of the given scopes,
+// either explicitly or through the admin scope.
+func (userInfo *UserInfo) HasScopes(scopes ...string) bool {
+	granted := make(map[string]bool, len(userInfo.Scopes))
+	for _, s := range userInfo.Scopes {
+		granted[s] = true
+	}
+
+	if granted[ScopeSearchAdmin] {
+		return true
+	}
+
+	for _, s := range scopes {
+		if !granted[s] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Authorize ensures that the user was granted all of the given scopes and
+// returns a ForbiddenError otherwise.
+func (userInfo *UserInfo) Authorize(scopes ...string) error {
+	if !userInfo.HasScopes(scopes...) {
+		return ForbiddenError{fmt.Sprintf("auth: missing required scopes \"%s\"", strings.Join(scopes, " "))}
+	}
+
+	return nil
+}
+
+// Config contains the information required to configure a validator to make
+// requests to validate a request's authorization header.
+type Config struct {
+	// Domain represents the domain where the user info endpoint is hosted.
+	Domain string
+}
+
+// Validate looks at the configuration's contents to ensure it has all the
+// required fields.
+func (conf *Config) validate() error {
+	if conf.Domain == "" {
+		return errors.New("missing domain")
+	}
+
+	return nil
+}
+
+// Validator is an interface representing the ability to validate an
+// authorization header and return the authenticated user's information.
+type Validator interface {
+	// Validate validates an authorization and returns the authenticated user's
+	// information.
+	Validate(ctx context.Context, authHeader string) (*UserInfo, error)
+}
+
+// A TokenValidator is a validator that validates a bearer token in an
+// authorization header by making a request to a /userinfo endpoint.
+type TokenValidator struct {
+	conf *Config
+}
+
+// validationTimeout represents how long to wait for the /userinfo endpoint to
+// respond before giving up on validating a token.
+const validationTimeout = 10 * time.Second
+
+// NewTokenValidator creates a new token validator with the given
+// configuration.
+func NewTokenValidator(conf *Config) (Validator, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("auth: missing configuration")
+	}
+
+	err := conf.validate()
+	if err != nil {
+		return nil, fmt.Errorf("auth: configuration %s", err)
+	}
+
+	return &TokenValidator{conf}, nil
+}
+
+// Validate makes a request to the /userinfo endpoint on the domain specified
+// in the token validator's configuration to validate the bearer token present
+// in the authorization header and returns the authenticated user's
+// information.
+func (validator *TokenValidator) Validate(ctx context.Context, authHeader string) (*UserInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, validationTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://"+validator.conf.Domain+"/userinfo", nil)
+	if err != nil {
+		return nil, UnauthorizedError{fmt.Sprintf("auth.TokenValidator: failed to create request (%s)", err)}
+	}
+
+	req.Header.Set("Authorization", authHeader)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, UnauthorizedError{fmt.Sprintf("auth: failed to make request (%s)", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, UnauthorizedError{fmt.Sprintf("auth: failed to validate token")}
+	}
+
+	var userInfo UserInfo
+	err = json.NewDecoder(resp.Body).Decode(&userInfo)
+	if err != nil {
+		return nil, UnauthorizedError{fmt.Sprintf("auth: failed to decode user info (%s)", err)}
+	}
+
+	userInfo.Scopes = scopesFromAuthHeader(authHeader)
+
+	return &userInfo, nil
+}
+
+// scopesFromAuthHeader extracts the scopes granted to the bearer token in the
+// authorization header from its claims. The token must already have been
+// validated, since its signature is not verified here.
+//
+// Both the space separated "scope" claim and the "permissions" claim added by
+// Auth0's role based access control are taken into account. A token that is
+// not a JWT carries no scopes.
+func scopesFromAuthHeader(authHeader string) []string {
+	token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil
+	}
+
+	var claims struct {
+		Scope       string   `json:"scope"`
+		Permissions []string `json:"permissions"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil
+	}
+
+	return append(strings.Fields(claims.Scope), claims.Permissions...)
+}
+
+type contextKey string
+
+func (c contextKey) String() string {
+	return "auth." + string(c)
+}
+
+const (
+	// UserInfoContextKey represents the key used to store and retrieve the
+	// user information from the request context.
+	UserInfoContextKey = contextKey("userInfo")
+
+	// TokenContextKey represents the key used to store and retrieve the
+	// authenticated user's bearer token from the request context.
+	TokenContextKey = contextKey("token")
+)
+
+// FromContext extracts an authenticated user's information from the request's
+// context.
+func FromContext(ctx context.Context) (*UserInfo, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("auth: request context is nil")
+	}
+
+	userInfo, ok := ctx.Value(UserInfoContextKey).(*UserInfo)
+	if !ok {
+		return nil, fmt.Errorf("auth: %s not found in context", UserInfoContextKey)
+	}
+
+	return userInfo, nil
+}
+
+// TokenFromContext extracts an authenticated user's bearer token from the
+// request's context.
+func TokenFromContext(ctx context.Context) (string, error) {
+	if ctx == nil {
+		return "", fmt.Errorf("auth: request context is nil")
+	}
+
+	token, ok := ctx.Value(TokenContextKey).(string)
+	if !ok {
+		return "", fmt.Errorf("auth: %s not found in context", TokenContextKey)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func newTestAuthHeader(payload string) string {
+	return "Bearer header." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+}
+
+func TestScopesFromAuthHeader(t *testing.T) {
+	t.Run("Should extract scopes from the scope and permissions claims", func(t *testing.T) {
+		scopes := scopesFromAuthHeader(newTestAuthHeader(`{"scope":"openid search:read","permissions":["search:create"]}`))
+
+		userInfo := UserInfo{Scopes: scopes}
+		if !userInfo.HasScopes(ScopeSearchRead, ScopeSearchCreate) {
+			t.Errorf("expected scopes to be extracted, got %v", scopes)
+		}
+	})
+
+	t.Run("Should extract no scopes from an opaque token", func(t *testing.T) {
+		scopes := scopesFromAuthHeader("Bearer opaque-token")
+		if len(scopes) != 0 {
+			t.Errorf("expected no scopes, got %v", scopes)
+		}
+	})
+}
+
+func TestUserInfoAuthorize(t *testing.T) {
+	t.Run("Should fail when a required scope is missing", func(t *testing.T) {
+		userInfo := UserInfo{Scopes: []string{ScopeSearchRead}}
+
+		if _, ok := userInfo.Authorize(ScopeSearchDelete).(ForbiddenError); !ok {
+			t.Fail()
+		}
+	})
+
+	t.Run("Should succeed when all required scopes are granted", func(t *testing.T) {
+		userInfo := UserInfo{Scopes: []string{ScopeSearchRead, ScopeSearchDelete}}
+
+		err := userInfo.Authorize(ScopeSearchRead, ScopeSearchDelete)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Should succeed for any scope when the admin scope is granted", func(t *testing.T) {
+		userInfo := UserInfo{Scopes: []string{ScopeSearchAdmin}}
+
+		err := userInfo.Authorize(ScopeSearchCreate)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package auth
+
+// An UnauthorizedError is an error that occurs when the user's authorization
+// could not be validated.
+type UnauthorizedError struct {
+	msg string
+}
+
+func (e UnauthorizedError) Error() string {
+	return e.msg
+}
+
+// A ForbiddenError is an error that occurs when the user is authenticated, but
+// was not granted the scopes required to access an endpoint.
+type ForbiddenError struct {
+	msg string
+}
+
+func (e ForbiddenError) Error() string {
+	return e.msg
+}
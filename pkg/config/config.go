@@ -0,0 +1,695 @@
+// Package config loads the service's configuration from an optional YAML file
+// and from environment variables, and validates it.
+//
+// Environment variables take precedence over the file, so that secrets do not
+// have to be written in it. Durations are given in seconds, like "30", or as Go
+// durations, like "1m30s".
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/auth"
+	"azure.com/ecovo/trip-search-service/pkg/db"
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/health"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/search"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
+	"azure.com/ecovo/trip-search-service/pkg/trip"
+	"azure.com/ecovo/trip-search-service/pkg/webhook"
+	"github.com/ably/ably-go/ably"
+	"github.com/google/uuid"
+	"googlemaps.github.io/maps"
+	"gopkg.in/yaml.v2"
+)
+
+// The ways requests to the trip service can be authenticated.
+const (
+	// TripAuthModeBasic sends a static token.
+	TripAuthModeBasic = "basic"
+
+	// TripAuthModeClientCredentials gets tokens with the OAuth 2.0 client
+	// credentials grant.
+	TripAuthModeClientCredentials = "client_credentials"
+
+	// TripAuthModeForward forwards the token of the request that started the
+	// search.
+	TripAuthModeForward = "forward"
+)
+
+const (
+	// DefaultPort represents the default port the service listens on.
+	DefaultPort = "8080"
+
+	// DefaultShutdownTimeout represents the default amount of time given to
+	// the service to shut down gracefully. Heroku kills processes that are
+	// still running 30 seconds after asking them to stop.
+	DefaultShutdownTimeout = 25 * time.Second
+)
+
+// A Duration is a time.Duration that can be read from a number of seconds or
+// from a Go duration.
+type Duration time.Duration
+
+// UnmarshalYAML reads a duration from a number of seconds or a Go duration.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	err := unmarshal(&s)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("\"%s\" is not a number of seconds or a duration", s)
+	}
+
+	return d, nil
+}
+
+// ServerConfig contains the information required to serve requests.
+type ServerConfig struct {
+	// Port specifies the port the service listens on.
+	Port string `yaml:"port"`
+
+	// ShutdownTimeout specifies how long the service has to shut down
+	// gracefully.
+	ShutdownTimeout Duration `yaml:"shutdownTimeout"`
+
+	// ReadinessDrainDelay specifies how long the service keeps handling
+	// requests after it starts reporting that it is not ready.
+	ReadinessDrainDelay Duration `yaml:"readinessDrainDelay"`
+
+	// HealthCheckTimeout specifies how long each dependency has to respond to
+	// a health probe.
+	HealthCheckTimeout Duration `yaml:"healthCheckTimeout"`
+}
+
+// LogConfig contains the information required to log.
+type LogConfig struct {
+	// Level specifies the minimum level of the entries to log, either debug,
+	// info, warn or error.
+	Level string `yaml:"level"`
+}
+
+// TracingConfig contains the information required to trace the service.
+type TracingConfig struct {
+	// Exporter specifies where to send traces, either none, stdout or otlp.
+	Exporter string `yaml:"exporter"`
+}
+
+// AuthConfig contains the information required to validate access tokens.
+type AuthConfig struct {
+	// Domain specifies the domain of the identity provider.
+	Domain string `yaml:"domain"`
+}
+
+// DBConfig contains the information required to connect to the database.
+type DBConfig struct {
+	// InMemory specifies whether to keep searches in memory instead of the
+	// database, which is only suited to tests and local development.
+	InMemory bool `yaml:"inMemory"`
+
+	// URI specifies a full connection string, which takes precedence over
+	// the host.
+	URI                    string      `yaml:"uri"`
+	Host                   string      `yaml:"host"`
+	Username               string      `yaml:"username"`
+	Password               string      `yaml:"password"`
+	AuthSource             string      `yaml:"authSource"`
+	ReplicaSet             string      `yaml:"replicaSet"`
+	Name                   string      `yaml:"name"`
+	TLS                    DBTLSConfig `yaml:"tls"`
+	MaxPoolSize            int         `yaml:"maxPoolSize"`
+	ConnectionTimeout      Duration    `yaml:"connectionTimeout"`
+	ServerSelectionTimeout Duration    `yaml:"serverSelectionTimeout"`
+}
+
+// DBTLSConfig contains the information required to secure the connection to
+// the database.
+type DBTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"caFile"`
+	CertificateKeyFile string `yaml:"certificateKeyFile"`
+	Insecure           bool   `yaml:"insecure"`
+}
+
+// AblyConfig contains the information required to connect to Ably.
+type AblyConfig struct {
+	APIKey string `yaml:"apiKey"`
+}
+
+// GoogleMapsConfig contains the information required to get routes from
+// Google Maps.
+type GoogleMapsConfig struct {
+	APIKey string `yaml:"apiKey"`
+}
+
+// TripServiceConfig contains the information required to make requests to the
+// trip service.
+type TripServiceConfig struct {
+	// SendMocks specifies whether to use mocked trips instead of the trip
+	// service.
+	SendMocks bool `yaml:"sendMocks"`
+
+	// Domain specifies the domain where the trip service is hosted.
+	Domain string `yaml:"domain"`
+
+	// AuthMode specifies how requests are authenticated, either basic,
+	// client_credentials or forward.
+	AuthMode string `yaml:"authMode"`
+
+	// Token specifies the token sent in basic mode.
+	Token string `yaml:"token"`
+
+	// TokenURL, ClientID, ClientSecret, Audience and Scopes specify how to
+	// get tokens in client_credentials mode.
+	TokenURL     string   `yaml:"tokenURL"`
+	ClientID     string   `yaml:"clientID"`
+	ClientSecret string   `yaml:"clientSecret"`
+	Audience     string   `yaml:"audience"`
+	Scopes       []string `yaml:"scopes"`
+
+	Timeout          Duration `yaml:"timeout"`
+	MaxRetries       int      `yaml:"maxRetries"`
+	BreakerThreshold int      `yaml:"breakerThreshold"`
+	BreakerCooldown  Duration `yaml:"breakerCooldown"`
+}
+
+// WorkerPoolConfig contains the information required to evaluate trips.
+type WorkerPoolConfig struct {
+	Concurrency int `yaml:"concurrency"`
+	QueueSize   int `yaml:"queueSize"`
+}
+
+// InstanceConfig contains the information required to share searches with
+// other instances.
+type InstanceConfig struct {
+	// ID specifies the unique identifier of the instance. A random one is
+	// generated when it is empty.
+	ID string `yaml:"id"`
+
+	// LeaseTTL specifies how long an instance keeps running a search after
+	// it last renewed its lease.
+	LeaseTTL Duration `yaml:"leaseTTL"`
+}
+
+// NotificationConfig contains the information required to notify users of
+// the trips matching their saved searches.
+type NotificationConfig struct {
+	// WebhookURL specifies a webhook every notification is delivered to, on
+	// top of the users' topics. No webhook is used when it is empty.
+	WebhookURL string `yaml:"webhookURL"`
+
+	// WebhookSecret specifies the secret the notifications are signed with.
+	WebhookSecret string `yaml:"webhookSecret"`
+}
+
+// WebhookConfig contains the information required to deliver events to
+// webhooks, like the notifications and the results of the searches that have
+// a callback URL.
+type WebhookConfig struct {
+	// Timeout specifies how long to wait for a webhook to respond.
+	Timeout Duration `yaml:"timeout"`
+
+	// MaxRetries specifies how many times a failed delivery is retried.
+	MaxRetries int `yaml:"maxRetries"`
+
+	// MaxConcurrentDeliveries specifies how many search results are
+	// delivered to callback URLs at once.
+	MaxConcurrentDeliveries int `yaml:"maxConcurrentDeliveries"`
+
+	// AllowPrivateNetworks specifies whether search results can be
+	// delivered to callback URLs on private, loopback and link-local
+	// addresses, like in local environments.
+	AllowPrivateNetworks bool `yaml:"allowPrivateNetworks"`
+}
+
+// Config contains the service's whole configuration.
+type Config struct {
+	Server      ServerConfig      `yaml:"server"`
+	Log         LogConfig         `yaml:"log"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Auth        AuthConfig        `yaml:"auth"`
+	DB          DBConfig          `yaml:"db"`
+	Ably        AblyConfig        `yaml:"ably"`
+	GoogleMaps  GoogleMapsConfig  `yaml:"googleMaps"`
+	TripService TripServiceConfig `yaml:"tripService"`
+	WorkerPool  WorkerPoolConfig  `yaml:"workerPool"`
+	Instance    InstanceConfig    `yaml:"instance"`
+
+	Notification NotificationConfig `yaml:"notification"`
+	Webhook      WebhookConfig      `yaml:"webhook"`
+}
+
+// Default returns the configuration used for the settings that are neither in
+// the file nor in the environment.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:               DefaultPort,
+			ShutdownTimeout:    Duration(DefaultShutdownTimeout),
+			HealthCheckTimeout: Duration(health.DefaultCheckTimeout),
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Tracing: TracingConfig{
+			Exporter: tracing.ExporterNone,
+		},
+		DB: DBConfig{
+			ConnectionTimeout: Duration(db.DefaultConnectionTimeout),
+		},
+		TripService: TripServiceConfig{
+			AuthMode:         TripAuthModeBasic,
+			Timeout:          Duration(trip.DefaultTimeout),
+			MaxRetries:       trip.DefaultMaxRetries,
+			BreakerThreshold: trip.DefaultBreakerThreshold,
+			BreakerCooldown:  Duration(trip.DefaultBreakerCooldown),
+		},
+		WorkerPool: WorkerPoolConfig{
+			Concurrency: search.DefaultPoolConcurrency,
+			QueueSize:   search.DefaultPoolQueueSize,
+		},
+		Instance: InstanceConfig{
+			LeaseTTL: Duration(search.DefaultLeaseTTL),
+		},
+		Webhook: WebhookConfig{
+			Timeout:                 Duration(webhook.DefaultTimeout),
+			MaxRetries:              webhook.DefaultMaxRetries,
+			MaxConcurrentDeliveries: webhook.DefaultMaxConcurrentDeliveries,
+		},
+	}
+}
+
+// Load reads the configuration from the YAML file at the given path, if the
+// path is not empty, then from the environment, and validates it. Every
+// problem found is reported at once.
+func Load(path string) (*Config, error) {
+	return load(path, (*Config).validate)
+}
+
+// LoadDB reads the configuration like Load, but only validates what is needed
+// to connect to the database, so that commands like migrations can run
+// without the credentials of the other dependencies.
+func LoadDB(path string) (*Config, error) {
+	return load(path, func(conf *Config) []string {
+		return append(conf.validateLog(), conf.validateDB()...)
+	})
+}
+
+func load(path string, validate func(*Config) []string) (*Config, error) {
+	conf := Default()
+
+	if path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: failed to read file (%s)", err)
+		}
+
+		err = yaml.UnmarshalStrict(content, conf)
+		if err != nil {
+			return nil, fmt.Errorf("config: failed to parse file (%s)", err)
+		}
+	}
+
+	problems := conf.loadEnv(os.LookupEnv)
+	problems = append(problems, validate(conf)...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("config: invalid configuration (%s)", strings.Join(problems, "; "))
+	}
+
+	if conf.Instance.ID == "" {
+		conf.Instance.ID = uuid.New().String()
+	}
+
+	return conf, nil
+}
+
+// loadEnv overrides the configuration with the environment variables that are
+// defined, and returns the ones that could not be parsed.
+func (conf *Config) loadEnv(lookup func(string) (string, bool)) []string {
+	e := &env{lookup: lookup}
+
+	e.string("PORT", &conf.Server.Port)
+	e.duration("SHUTDOWN_TIMEOUT", &conf.Server.ShutdownTimeout)
+	e.duration("READINESS_DRAIN_DELAY", &conf.Server.ReadinessDrainDelay)
+	e.duration("HEALTH_CHECK_TIMEOUT", &conf.Server.HealthCheckTimeout)
+
+	e.string("LOG_LEVEL", &conf.Log.Level)
+	e.string("TRACING_EXPORTER", &conf.Tracing.Exporter)
+
+	e.string("AUTH_DOMAIN", &conf.Auth.Domain)
+
+	e.bool("DB_IN_MEMORY", &conf.DB.InMemory)
+	e.string("DB_URI", &conf.DB.URI)
+	e.string("DB_HOST", &conf.DB.Host)
+	e.string("DB_USERNAME", &conf.DB.Username)
+	e.string("DB_PASSWORD", &conf.DB.Password)
+	e.string("DB_AUTH_SOURCE", &conf.DB.AuthSource)
+	e.string("DB_REPLICA_SET", &conf.DB.ReplicaSet)
+	e.string("DB_NAME", &conf.DB.Name)
+	e.bool("DB_TLS", &conf.DB.TLS.Enabled)
+	e.string("DB_TLS_CA_FILE", &conf.DB.TLS.CAFile)
+	e.string("DB_TLS_CERTIFICATE_KEY_FILE", &conf.DB.TLS.CertificateKeyFile)
+	e.bool("DB_TLS_INSECURE", &conf.DB.TLS.Insecure)
+	e.int("DB_MAX_POOL_SIZE", &conf.DB.MaxPoolSize)
+	e.duration("DB_CONNECTION_TIMEOUT", &conf.DB.ConnectionTimeout)
+	e.duration("DB_SERVER_SELECTION_TIMEOUT", &conf.DB.ServerSelectionTimeout)
+
+	e.string("ABLY_API_KEY", &conf.Ably.APIKey)
+	e.string("GOOGLE_MAPS_API_KEY", &conf.GoogleMaps.APIKey)
+
+	e.bool("SEND_MOCKS", &conf.TripService.SendMocks)
+	e.string("TRIP_SERVICE_DOMAIN", &conf.TripService.Domain)
+	e.string("TRIP_SERVICE_AUTH_MODE", &conf.TripService.AuthMode)
+	e.string("TRIP_SERVICE_AUTH", &conf.TripService.Token)
+	e.string("TRIP_SERVICE_TOKEN_URL", &conf.TripService.TokenURL)
+	e.string("TRIP_SERVICE_CLIENT_ID", &conf.TripService.ClientID)
+	e.string("TRIP_SERVICE_CLIENT_SECRET", &conf.TripService.ClientSecret)
+	e.string("TRIP_SERVICE_AUDIENCE", &conf.TripService.Audience)
+	e.fields("TRIP_SERVICE_SCOPES", &conf.TripService.Scopes)
+	e.duration("TRIP_SERVICE_TIMEOUT", &conf.TripService.Timeout)
+	e.int("TRIP_SERVICE_MAX_RETRIES", &conf.TripService.MaxRetries)
+	e.int("TRIP_SERVICE_BREAKER_THRESHOLD", &conf.TripService.BreakerThreshold)
+	e.duration("TRIP_SERVICE_BREAKER_COOLDOWN", &conf.TripService.BreakerCooldown)
+
+	e.int("WORKER_POOL_CONCURRENCY", &conf.WorkerPool.Concurrency)
+	e.int("WORKER_POOL_QUEUE_SIZE", &conf.WorkerPool.QueueSize)
+
+	e.string("INSTANCE_ID", &conf.Instance.ID)
+	e.duration("LEASE_TTL", &conf.Instance.LeaseTTL)
+
+	e.string("NOTIFICATION_WEBHOOK_URL", &conf.Notification.WebhookURL)
+	e.string("NOTIFICATION_WEBHOOK_SECRET", &conf.Notification.WebhookSecret)
+
+	e.duration("WEBHOOK_TIMEOUT", &conf.Webhook.Timeout)
+	e.int("WEBHOOK_MAX_RETRIES", &conf.Webhook.MaxRetries)
+	e.int("WEBHOOK_MAX_CONCURRENT_DELIVERIES", &conf.Webhook.MaxConcurrentDeliveries)
+	e.bool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", &conf.Webhook.AllowPrivateNetworks)
+
+	return e.problems
+}
+
+// validate returns every problem found in the configuration.
+func (conf *Config) validate() []string {
+	var problems []string
+	require := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	require(conf.Server.Port != "", "port is missing")
+	require(conf.Server.ShutdownTimeout > 0, "shutdown timeout must be greater than 0")
+	require(conf.Server.ReadinessDrainDelay >= 0, "readiness drain delay cannot be negative")
+	require(conf.Server.HealthCheckTimeout > 0, "health check timeout must be greater than 0")
+
+	problems = append(problems, conf.validateLog()...)
+
+	switch conf.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		problems = append(problems, "tracing exporter must be none, stdout or otlp")
+	}
+
+	require(conf.Auth.Domain != "", "auth domain is missing")
+
+	problems = append(problems, conf.validateDB()...)
+
+	require(conf.Ably.APIKey != "", "Ably API key is missing")
+	require(conf.GoogleMaps.APIKey != "", "Google Maps API key is missing")
+
+	if !conf.TripService.SendMocks {
+		t := conf.TripService
+
+		require(t.Domain != "", "trip service domain is missing")
+
+		switch t.AuthMode {
+		case TripAuthModeBasic:
+			require(t.Token != "", "trip service token is missing")
+		case TripAuthModeClientCredentials:
+			require(t.TokenURL != "", "trip service token URL is missing")
+			require(t.ClientID != "", "trip service client ID is missing")
+			require(t.ClientSecret != "", "trip service client secret is missing")
+		case TripAuthModeForward:
+			// The searches taken over from another instance are not run on
+			// behalf of a user, so there is no token to forward for them.
+			require(conf.DB.InMemory, "trip service auth mode forward cannot be used with a shared database")
+		default:
+			problems = append(problems, "trip service auth mode must be basic, client_credentials or forward")
+		}
+
+		require(t.Timeout > 0, "trip service timeout must be greater than 0")
+		require(t.MaxRetries >= 0, "trip service max retries cannot be negative")
+		require(t.BreakerThreshold >= 0, "trip service breaker threshold cannot be negative")
+		require(t.BreakerCooldown > 0, "trip service breaker cooldown must be greater than 0")
+	}
+
+	require(conf.WorkerPool.Concurrency > 0, "worker pool concurrency must be greater than 0")
+	require(conf.WorkerPool.QueueSize >= 0, "worker pool queue size cannot be negative")
+
+	require(conf.Instance.LeaseTTL > 0, "lease TTL must be greater than 0")
+
+	if n := conf.Notification; n.WebhookURL != "" {
+		require(entity.ValidateURL(n.WebhookURL) == nil, "notification webhook URL must be an HTTP or HTTPS URL")
+	} else {
+		require(n.WebhookSecret == "", "notification webhook secret is given without a webhook URL")
+	}
+
+	require(conf.Webhook.Timeout > 0, "webhook timeout must be greater than 0")
+	require(conf.Webhook.MaxRetries >= 0, "webhook max retries cannot be negative")
+	require(conf.Webhook.MaxConcurrentDeliveries > 0, "webhook max concurrent deliveries must be greater than 0")
+
+	return problems
+}
+
+// validateLog returns the problems found in the logging configuration.
+func (conf *Config) validateLog() []string {
+	_, err := logging.ParseLevel(conf.Log.Level)
+	if err != nil {
+		return []string{"log level must be debug, info, warn or error"}
+	}
+
+	return nil
+}
+
+// validateDB returns the problems found in the database configuration.
+func (conf *Config) validateDB() []string {
+	if conf.DB.InMemory {
+		return nil
+	}
+
+	var problems []string
+	require := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	require(conf.DB.URI != "" || conf.DB.Host != "", "database URI or host is missing")
+	require(conf.DB.Username == "" || conf.DB.Password != "", "database password is missing")
+	require(conf.DB.Name != "", "database name is missing")
+	require(conf.DB.TLS.Enabled || (conf.DB.TLS.CAFile == "" && conf.DB.TLS.CertificateKeyFile == "" && !conf.DB.TLS.Insecure),
+		"database TLS settings are given without enabling TLS")
+	require(conf.DB.MaxPoolSize >= 0 && conf.DB.MaxPoolSize <= math.MaxUint16,
+		fmt.Sprintf("database max pool size must be between 0 and %d", math.MaxUint16))
+	require(conf.DB.ConnectionTimeout >= 0, "database connection timeout cannot be negative")
+	require(conf.DB.ServerSelectionTimeout >= 0, "database server selection timeout cannot be negative")
+
+	return problems
+}
+
+// AuthConfig returns the configuration of the access token validator.
+func (conf *Config) AuthConfig() *auth.Config {
+	return &auth.Config{Domain: conf.Auth.Domain}
+}
+
+// DBConfig returns the configuration of the database connection.
+func (conf *Config) DBConfig() *db.Config {
+	return &db.Config{
+		URI:        conf.DB.URI,
+		Host:       conf.DB.Host,
+		Username:   conf.DB.Username,
+		Password:   conf.DB.Password,
+		AuthSource: conf.DB.AuthSource,
+		ReplicaSet: conf.DB.ReplicaSet,
+		Name:       conf.DB.Name,
+		TLS: db.TLSConfig{
+			Enabled:            conf.DB.TLS.Enabled,
+			CAFile:             conf.DB.TLS.CAFile,
+			CertificateKeyFile: conf.DB.TLS.CertificateKeyFile,
+			Insecure:           conf.DB.TLS.Insecure,
+		},
+		MaxPoolSize:            uint16(conf.DB.MaxPoolSize),
+		ConnectionTimeout:      time.Duration(conf.DB.ConnectionTimeout),
+		ServerSelectionTimeout: time.Duration(conf.DB.ServerSelectionTimeout),
+	}
+}
+
+// AblyOptions returns the options of the Ably client used for pub/sub.
+func (conf *Config) AblyOptions() *ably.ClientOptions {
+	return ably.NewClientOptions(conf.Ably.APIKey)
+}
+
+// GoogleMapsOptions returns the options of the Google Maps client used to get
+// routes.
+func (conf *Config) GoogleMapsOptions() []maps.ClientOption {
+	return []maps.ClientOption{maps.WithAPIKey(conf.GoogleMaps.APIKey)}
+}
+
+// TripClientConfig returns the configuration of the trip service client.
+func (conf *Config) TripClientConfig() *trip.ClientConfig {
+	c := trip.DefaultClientConfig()
+	c.Timeout = time.Duration(conf.TripService.Timeout)
+	c.MaxRetries = conf.TripService.MaxRetries
+	c.BreakerThreshold = conf.TripService.BreakerThreshold
+	c.BreakerCooldown = time.Duration(conf.TripService.BreakerCooldown)
+
+	return c
+}
+
+// TripCredentials returns the credentials used to authenticate requests to the
+// trip service.
+func (conf *Config) TripCredentials() (trip.Credentials, error) {
+	t := conf.TripService
+
+	switch t.AuthMode {
+	case TripAuthModeBasic:
+		return trip.NewBasicCredentials(t.Token)
+	case TripAuthModeClientCredentials:
+		return trip.NewClientCredentials(&trip.ClientCredentialsConfig{
+			TokenURL:     t.TokenURL,
+			ClientID:     t.ClientID,
+			ClientSecret: t.ClientSecret,
+			Audience:     t.Audience,
+			Scopes:       t.Scopes}, &http.Client{Timeout: time.Duration(t.Timeout)})
+	case TripAuthModeForward:
+		return trip.NewForwardedCredentials(auth.TokenFromContext)
+	default:
+		return nil, fmt.Errorf("config: unknown trip service auth mode \"%s\"", t.AuthMode)
+	}
+}
+
+// LeaseConfig returns the configuration used to share searches with other
+// instances.
+func (conf *Config) LeaseConfig() *search.LeaseConfig {
+	return &search.LeaseConfig{
+		InstanceID: conf.Instance.ID,
+		TTL:        time.Duration(conf.Instance.LeaseTTL),
+	}
+}
+
+// WebhookClientConfig returns the configuration of the client that delivers
+// search results to callback URLs.
+func (conf *Config) WebhookClientConfig() *webhook.ClientConfig {
+	return &webhook.ClientConfig{
+		Timeout:              time.Duration(conf.Webhook.Timeout),
+		MaxRetries:           conf.Webhook.MaxRetries,
+		AllowPrivateNetworks: conf.Webhook.AllowPrivateNetworks,
+	}
+}
+
+// NotificationClientConfig returns the configuration of the client that
+// delivers notifications to the notification webhook. Failed notifications are
+// not retried, so that a webhook that cannot be reached does not hold back the
+// matching of saved searches, and the webhook can be on a private network,
+// since its URL is not given by users.
+func (conf *Config) NotificationClientConfig() *webhook.ClientConfig {
+	return &webhook.ClientConfig{
+		Timeout:              time.Duration(conf.Webhook.Timeout),
+		AllowPrivateNetworks: true,
+	}
+}
+
+// TracingConfig returns the configuration of the tracing provider.
+func (conf *Config) TracingConfig() *tracing.Config {
+	return &tracing.Config{Exporter: conf.Tracing.Exporter}
+}
+
+// An env reads environment variables into the configuration, and keeps track
+// of the ones that could not be parsed.
+type env struct {
+	lookup   func(string) (string, bool)
+	problems []string
+}
+
+func (e *env) string(name string, dst *string) {
+	if value, ok := e.lookup(name); ok && value != "" {
+		*dst = value
+	}
+}
+
+func (e *env) fields(name string, dst *[]string) {
+	if value, ok := e.lookup(name); ok && value != "" {
+		*dst = strings.Fields(value)
+	}
+}
+
+func (e *env) int(name string, dst *int) {
+	value, ok := e.lookup(name)
+	if !ok || value == "" {
+		return
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s must be an integer", name))
+		return
+	}
+
+	*dst = i
+}
+
+func (e *env) bool(name string, dst *bool) {
+	value, ok := e.lookup(name)
+	if !ok || value == "" {
+		return
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s must be true or false", name))
+		return
+	}
+
+	*dst = b
+}
+
+func (e *env) duration(name string, dst *Duration) {
+	value, ok := e.lookup(name)
+	if !ok || value == "" {
+		return
+	}
+
+	d, err := parseDuration(value)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s must be a number of seconds or a duration", name))
+		return
+	}
+
+	*dst = Duration(d)
+}
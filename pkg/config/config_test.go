@@ -0,0 +1,176 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var requiredEnv = map[string]string{
+	"AUTH_DOMAIN":         "auth.example.com",
+	"DB_HOST":             "localhost:27017",
+	"DB_USERNAME":         "user",
+	"DB_PASSWORD":         "password",
+	"DB_NAME":             "searches",
+	"ABLY_API_KEY":        "ably",
+	"GOOGLE_MAPS_API_KEY": "maps",
+	"TRIP_SERVICE_DOMAIN": "trips.example.com",
+	"TRIP_SERVICE_AUTH":   "token",
+}
+
+func setEnv(t *testing.T, env map[string]string) {
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Should use the defaults for the settings that are not defined", func(t *testing.T) {
+		setEnv(t, requiredEnv)
+
+		conf, err := Load("")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if conf.Server.Port != DefaultPort {
+			t.Errorf("expected port %s, got %s", DefaultPort, conf.Server.Port)
+		}
+
+		if conf.Instance.ID == "" {
+			t.Error("expected an instance ID to be generated")
+		}
+	})
+
+	t.Run("Should report every problem at once", func(t *testing.T) {
+		setEnv(t, requiredEnv)
+		t.Setenv("DB_CONNECTION_TIMEOUT", "soon")
+		t.Setenv("WORKER_POOL_CONCURRENCY", "many")
+		t.Setenv("ABLY_API_KEY", "")
+
+		_, err := Load("")
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		for _, problem := range []string{"DB_CONNECTION_TIMEOUT", "WORKER_POOL_CONCURRENCY", "Ably API key"} {
+			if !strings.Contains(err.Error(), problem) {
+				t.Errorf("expected error to mention %s, got %s", problem, err)
+			}
+		}
+	})
+
+	t.Run("Should not require the database when searches are kept in memory", func(t *testing.T) {
+		setEnv(t, requiredEnv)
+		t.Setenv("DB_IN_MEMORY", "true")
+		t.Setenv("DB_HOST", "")
+		t.Setenv("DB_NAME", "")
+
+		_, err := Load("")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Should reject a notification webhook secret without a URL", func(t *testing.T) {
+		setEnv(t, requiredEnv)
+		t.Setenv("NOTIFICATION_WEBHOOK_SECRET", "secret")
+
+		_, err := Load("")
+		if err == nil || !strings.Contains(err.Error(), "webhook secret") {
+			t.Errorf("expected an error about the webhook secret, got %v", err)
+		}
+	})
+
+	t.Run("Should reject forwarding tokens when searches are shared", func(t *testing.T) {
+		setEnv(t, requiredEnv)
+		t.Setenv("TRIP_SERVICE_AUTH_MODE", "forward")
+
+		_, err := Load("")
+		if err == nil || !strings.Contains(err.Error(), "forward") {
+			t.Errorf("expected an error about the auth mode, got %v", err)
+		}
+
+		t.Setenv("DB_IN_MEMORY", "true")
+
+		_, err = Load("")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Should let the environment override the file", func(t *testing.T) {
+		setEnv(t, requiredEnv)
+		t.Setenv("LEASE_TTL", "45")
+
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		err := ioutil.WriteFile(path, []byte(`
+server:
+  port: "9090"
+instance:
+  leaseTTL: 1m
+tripService:
+  timeout: 3
+`), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conf, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if conf.Server.Port != "9090" {
+			t.Errorf("expected port 9090, got %s", conf.Server.Port)
+		}
+
+		if time.Duration(conf.Instance.LeaseTTL) != 45*time.Second {
+			t.Errorf("expected lease TTL 45s, got %s", time.Duration(conf.Instance.LeaseTTL))
+		}
+
+		if time.Duration(conf.TripService.Timeout) != 3*time.Second {
+			t.Errorf("expected trip service timeout 3s, got %s", time.Duration(conf.TripService.Timeout))
+		}
+	})
+
+	t.Run("Should reject unknown settings in the file", func(t *testing.T) {
+		setEnv(t, requiredEnv)
+
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		err := ioutil.WriteFile(path, []byte("server:\n  prot: \"9090\"\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Load(path)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestLoadDB(t *testing.T) {
+	t.Run("Should only require the database", func(t *testing.T) {
+		setEnv(t, map[string]string{
+			"DB_HOST": "localhost:27017",
+			"DB_NAME": "searches",
+		})
+
+		_, err := LoadDB("")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Should reject an invalid database configuration", func(t *testing.T) {
+		t.Setenv("DB_HOST", "localhost:27017")
+
+		_, err := LoadDB("")
+		if err == nil || !strings.Contains(err.Error(), "database name") {
+			t.Errorf("expected the missing database name to be reported, got %v", err)
+		}
+	})
+}
@@ -4,14 +4,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/mongo/options"
 )
 
 // Config contains the information required to connect to a database.
+//
+// The database server is either given as a full connection string, or as a
+// host and credentials. The other settings override the ones found in the
+// connection string when they are set.
 type Config struct {
-	// Host specifies the URI to where the database is hosted.
+	// URI specifies the connection string of the database server, with the
+	// mongodb or mongodb+srv scheme. When it is set, Host is ignored.
+	URI string
+
+	// Host specifies the address where the database server is hosted. A
+	// replica set's members are separated by commas.
 	Host string
 
 	// Username specifies the name of the database user to use when
@@ -22,14 +33,54 @@ type Config struct {
 	// the connection to the database server.
 	Password string
 
+	// AuthSource specifies the database the user is authenticated against.
+	AuthSource string
+
+	// ReplicaSet specifies the name of the replica set to connect to.
+	ReplicaSet string
+
 	// Name specifies the name of the database to use on the server.
 	Name string
 
+	// TLS specifies how the connection to the database server is secured.
+	TLS TLSConfig
+
+	// MaxPoolSize specifies how many connections can be open to each server
+	// at once.
+	//
+	// A size of zero means the driver's default.
+	MaxPoolSize uint16
+
 	// ConnectionTimeout specifies how many seconds to wait before giving up on
 	// connecting to the database server.
 	//
 	// A timeout of zero means no timeout.
 	ConnectionTimeout time.Duration
+
+	// ServerSelectionTimeout specifies how long to wait for a suitable server
+	// to be available before giving up on an operation.
+	//
+	// A timeout of zero means the driver's default.
+	ServerSelectionTimeout time.Duration
+}
+
+// TLSConfig contains the information required to secure the connection to the
+// database server.
+type TLSConfig struct {
+	// Enabled specifies whether the connection is secured with TLS.
+	Enabled bool
+
+	// CAFile specifies the file containing the certificate authorities used
+	// to verify the server's certificate.
+	CAFile string
+
+	// CertificateKeyFile specifies the file containing the client certificate
+	// and private key used to authenticate the client.
+	CertificateKeyFile string
+
+	// Insecure specifies whether to skip verifying the server's certificate
+	// and host name.
+	Insecure bool
 }
 
 // DefaultConnectionTimeout represents the default amount of time to wait while
@@ -39,15 +90,11 @@ const DefaultConnectionTimeout = 20 * time.Second
 // Validate looks at the configuration's contents to ensure it has all the
 // required fields.
 func (conf *Config) validate() error {
-	if conf.Host == "" {
-		return errors.New("missing host")
+	if conf.URI == "" && conf.Host == "" {
+		return errors.New("missing URI or host")
 	}
 
-	if conf.Username == "" {
-		return errors.New("missing username")
-	}
-
-	if conf.Password == "" {
+	if conf.Username != "" && conf.Password == "" {
 		return errors.New("missing password")
 	}
 
@@ -55,18 +102,91 @@ func (conf *Config) validate() error {
 		return errors.New("missing name")
 	}
 
+	if !conf.TLS.Enabled && (conf.TLS.CAFile != "" || conf.TLS.CertificateKeyFile != "" || conf.TLS.Insecure) {
+		return errors.New("TLS settings given without enabling TLS")
+	}
+
 	return nil
 }
 
+// uri returns the connection string of the database server. The credentials
+// are not part of it, so that they do not need to be escaped.
+func (conf *Config) uri() string {
+	if conf.URI != "" {
+		return conf.URI
+	}
+
+	return (&url.URL{Scheme: "mongodb", Host: conf.Host, Path: "/"}).String()
+}
+
+// clientOptions returns the options that override the connection string.
+func (conf *Config) clientOptions() *options.ClientOptions {
+	opts := options.Client()
+
+	if conf.Username != "" {
+		opts.SetAuth(options.Credential{
+			Username:   conf.Username,
+			Password:   conf.Password,
+			AuthSource: conf.AuthSource,
+		})
+	} else if conf.AuthSource != "" {
+		opts.SetAuth(options.Credential{AuthSource: conf.AuthSource})
+	}
+
+	if conf.ReplicaSet != "" {
+		opts.SetReplicaSet(conf.ReplicaSet)
+	}
+
+	if conf.TLS.Enabled {
+		opts.SetSSL(&options.SSLOpt{
+			Enabled:                  true,
+			CaFile:                   conf.TLS.CAFile,
+			ClientCertificateKeyFile: conf.TLS.CertificateKeyFile,
+			Insecure:                 conf.TLS.Insecure,
+		})
+	}
+
+	if conf.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(conf.MaxPoolSize)
+	}
+
+	if conf.ConnectionTimeout > 0 {
+		opts.SetConnectTimeout(conf.ConnectionTimeout)
+	}
+
+	if conf.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(conf.ServerSelectionTimeout)
+	}
+
+	return opts
+}
+
 // DB represents a database. It contains a client used to connect to a database
 // server and the database's collections.
 type DB struct {
-	client   *mongo.Client
-	Searches *mongo.Collection
+	client     *mongo.Client
+	database   *mongo.Database
+	migrations *mongo.Collection
+	Searches   *mongo.Collection
+
+	// SearchRecords contains the records of the searches that ended, for
+	// analytics. Records are only ever appended to it.
+	SearchRecords *mongo.Collection
+
+	// SavedSearches contains the searches saved by riders to be notified of
+	// matching trips. They expire once their travel time has passed.
+	SavedSearches *mongo.Collection
+
+	// WebhookDeliveries contains the outcome of the deliveries of search
+	// results to callback URLs. They expire a week after they end.
+	WebhookDeliveries *mongo.Collection
 }
 
 const (
-	searchCollectionName = "searches"
+	searchCollectionName          = "searches"
+	searchRecordCollectionName    = "searchRecords"
+	savedSearchCollectionName     = "savedSearches"
+	webhookDeliveryCollectionName = "webhookDeliveries"
 )
 
 // New creates a database by establishing a connection to the database server
@@ -81,14 +201,17 @@ func New(conf *Config) (*DB, error) {
 		return nil, fmt.Errorf("db: configuration %s", err)
 	}
 
-	url := fmt.Sprintf("mongodb://%s:%s@%s", conf.Username, conf.Password, conf.Host)
-	client, err := mongo.NewClient(url)
+	client, err := mongo.NewClientWithOptions(conf.uri(), conf.clientOptions())
 	if err != nil {
 		return nil, fmt.Errorf("db: failed to create client (%s)", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
-	defer cancel()
+	ctx := context.Background()
+	if conf.ConnectionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.ConnectionTimeout)
+		defer cancel()
+	}
 
 	err = client.Connect(ctx)
 	if err != nil {
@@ -110,5 +233,33 @@ func New(conf *Config) (*DB, error) {
 		return nil, fmt.Errorf("db: no collection found with name \"%s\" in database", searchCollectionName)
 	}
 
-	return &DB{client, searches}, nil
+	return &DB{
+		client:            client,
+		database:          db,
+		migrations:        db.Collection(migrationCollectionName),
+		Searches:          searches,
+		SearchRecords:     db.Collection(searchRecordCollectionName),
+		SavedSearches:     db.Collection(savedSearchCollectionName),
+		WebhookDeliveries: db.Collection(webhookDeliveryCollectionName),
+	}, nil
+}
+
+// Disconnect closes the connection to the database server.
+func (db *DB) Disconnect(ctx context.Context) error {
+	err := db.client.Disconnect(ctx)
+	if err != nil {
+		return fmt.Errorf("db: failed to disconnect from server (%s)", err)
+	}
+
+	return nil
+}
+
+// Ping checks that the database server can be reached.
+func (db *DB) Ping(ctx context.Context) error {
+	err := db.client.Ping(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("db: failed to reach server (%s)", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mongodb/mongo-go-driver/mongo"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Run("Should accept a URI without a host or credentials", func(t *testing.T) {
+		conf := &Config{URI: "mongodb+srv://cluster.example.com/?authSource=admin", Name: "searches"}
+
+		err := conf.validate()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Should require a URI or a host", func(t *testing.T) {
+		conf := &Config{Name: "searches"}
+
+		err := conf.validate()
+		if err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+
+	t.Run("Should require TLS to be enabled to use TLS settings", func(t *testing.T) {
+		conf := &Config{Host: "localhost:27017", Name: "searches", TLS: TLSConfig{CAFile: "ca.pem"}}
+
+		err := conf.validate()
+		if err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+}
+
+func TestConfigClientOptions(t *testing.T) {
+	t.Run("Should keep the credentials out of the URI", func(t *testing.T) {
+		conf := &Config{Host: "localhost:27017", Username: "user@example.com", Password: "p@ss:w/rd", Name: "searches"}
+
+		uri := conf.uri()
+		if uri != "mongodb://localhost:27017/" {
+			t.Errorf("expected URI without credentials, got %s", uri)
+		}
+
+		cs := conf.clientOptions().ConnString
+		if cs.Username != conf.Username || cs.Password != conf.Password {
+			t.Errorf("expected credentials to be set as is, got %s and %s", cs.Username, cs.Password)
+		}
+	})
+
+	t.Run("Should only override the settings that are given", func(t *testing.T) {
+		conf := &Config{URI: "mongodb://localhost:27017/?ssl=true&maxPoolSize=10", Name: "searches"}
+
+		cs := conf.clientOptions().ConnString
+		if cs.Username != "" || cs.SSLSet || cs.MaxPoolSizeSet || cs.ServerSelectionTimeoutSet {
+			t.Errorf("expected no overrides, got %+v", cs)
+		}
+	})
+
+	t.Run("Should set TLS, pool size and server selection timeout", func(t *testing.T) {
+		conf := &Config{
+			Host:                   "localhost:27017",
+			Name:                   "searches",
+			TLS:                    TLSConfig{Enabled: true, CAFile: "ca.pem"},
+			MaxPoolSize:            50,
+			ServerSelectionTimeout: 5 * time.Second,
+		}
+
+		cs := conf.clientOptions().ConnString
+		if !cs.SSL || cs.SSLCaFile != "ca.pem" {
+			t.Errorf("expected TLS with CA file, got %t and %s", cs.SSL, cs.SSLCaFile)
+		}
+		if !cs.MaxPoolSizeSet || cs.MaxPoolSize != 50 {
+			t.Errorf("expected max pool size 50, got %d", cs.MaxPoolSize)
+		}
+		if !cs.ServerSelectionTimeoutSet || cs.ServerSelectionTimeout != 5*time.Second {
+			t.Errorf("expected server selection timeout 5s, got %s", cs.ServerSelectionTimeout)
+		}
+	})
+}
+
+func TestValidateMigrations(t *testing.T) {
+	t.Run("Should accept the migrations of the database", func(t *testing.T) {
+		err := validateMigrations(migrations)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Should reject migrations out of order", func(t *testing.T) {
+		up := func(context.Context, *mongo.Database) error { return nil }
+
+		err := validateMigrations([]Migration{{Version: 2, Up: up}, {Version: 1, Up: up}})
+		if err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+}
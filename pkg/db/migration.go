@@ -0,0 +1,337 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/mongo/options"
+)
+
+// A Migration changes the schema of the database, such as its indexes, from
+// one version to the next.
+//
+// Migrations must be idempotent, since an instance can stop after applying a
+// migration but before recording it, or several instances can apply the same
+// migration when they start at the same time.
+type Migration struct {
+	// Version specifies the order in which migrations are applied. It must
+	// never change once the migration is released.
+	Version int
+
+	// Description specifies what the migration does.
+	Description string
+
+	// Up applies the migration to the database.
+	Up func(ctx context.Context, db *mongo.Database) error
+}
+
+// A MigrationState describes a migration and whether it was applied to the
+// database.
+type MigrationState struct {
+	Version     int
+	Description string
+
+	// AppliedAt specifies when the migration was recorded as applied. It is
+	// zero for a pending migration.
+	AppliedAt time.Time
+}
+
+// Pending returns whether the migration has yet to be applied.
+func (s *MigrationState) Pending() bool {
+	return s.AppliedAt.IsZero()
+}
+
+type migrationDocument struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+const (
+	migrationCollectionName = "migrations"
+
+	// migrationTimeout represents how long a single migration can take.
+	migrationTimeout = time.Minute
+)
+
+// migrations contains the migrations of the database, in order. New ones are
+// appended to it.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "index searches by lease owner and status",
+		Up: createIndexes(searchCollectionName, mongo.IndexModel{
+			Keys:    bson.D{{Key: "owner", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("owner_status"),
+		}),
+	},
+	{
+		Version:     2,
+		Description: "index searches by status and lease expiry",
+		Up: createIndexes(searchCollectionName, mongo.IndexModel{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "leaseExpiresAt", Value: 1}},
+			Options: options.Index().SetName("status_leaseExpiresAt"),
+		}),
+	},
+	{
+		Version:     3,
+		Description: "index search records by booking and end",
+		Up: createIndexes(searchRecordCollectionName, mongo.IndexModel{
+			Keys:    bson.D{{Key: "booked", Value: 1}, {Key: "endedAt", Value: 1}},
+			Options: options.Index().SetName("booked_endedAt"),
+		}),
+	},
+	{
+		Version:     4,
+		Description: "index saved searches by user and expire them after their travel time",
+		Up: createIndexes(savedSearchCollectionName,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "userId", Value: 1}},
+				Options: options.Index().SetName("userId"),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "travelAt", Value: 1}},
+				Options: options.Index().SetName("travelAt_ttl").SetExpireAfterSeconds(0),
+			},
+		),
+	},
+	{
+		Version:     5,
+		Description: "index webhook deliveries by subject and expire them after a week",
+		Up: createIndexes(webhookDeliveryCollectionName,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "subject", Value: 1}, {Key: "endedAt", Value: -1}},
+				Options: options.Index().SetName("subject_endedAt"),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "endedAt", Value: 1}},
+				Options: options.Index().SetName("endedAt_ttl").SetExpireAfterSeconds(int32(webhookDeliveryTTL / time.Second)),
+			},
+		),
+	},
+	{
+		Version:     6,
+		Description: "index search records by booking, end and results",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// The new index starts with the keys of the one it replaces, so
+			// the records stay indexed while it is built.
+			err := createIndexes(searchRecordCollectionName, mongo.IndexModel{
+				Keys:    bson.D{{Key: "booked", Value: 1}, {Key: "endedAt", Value: 1}, {Key: "results", Value: 1}},
+				Options: options.Index().SetName("booked_endedAt_results"),
+			})(ctx, db)
+			if err != nil {
+				return err
+			}
+
+			return dropIndex(searchRecordCollectionName, "booked_endedAt")(ctx, db)
+		},
+	},
+	{
+		Version:     7,
+		Description: "index searches by expiry and results",
+		Up: createIndexes(searchCollectionName,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetName("expiresAt"),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "results", Value: 1}},
+				Options: options.Index().SetName("results"),
+			},
+		),
+	},
+}
+
+// webhookDeliveryTTL represents how long the outcome of webhook deliveries is
+// kept.
+const webhookDeliveryTTL = 7 * 24 * time.Hour
+
+// createIndexes returns a migration that creates the indexes on a collection.
+// Creating an index that already exists with the same keys and options does
+// nothing.
+func createIndexes(collection string, models ...mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().CreateMany(ctx, models)
+		if err != nil {
+			return fmt.Errorf("failed to create indexes on \"%s\" (%s)", collection, err)
+		}
+
+		return nil
+	}
+}
+
+// dropIndex returns a migration that drops the index with the given name from
+// a collection. Dropping an index that does not exist does nothing.
+func dropIndex(collection string, name string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		indexes := db.Collection(collection).Indexes()
+
+		cur, err := indexes.List(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list indexes on \"%s\" (%s)", collection, err)
+		}
+		defer cur.Close(ctx)
+
+		found := false
+		for cur.Next(ctx) {
+			var index struct {
+				Name string `bson:"name"`
+			}
+			err = cur.Decode(&index)
+			if err != nil {
+				return fmt.Errorf("failed to decode index on \"%s\" (%s)", collection, err)
+			}
+
+			found = found || index.Name == name
+		}
+
+		if err = cur.Err(); err != nil {
+			return fmt.Errorf("failed to list indexes on \"%s\" (%s)", collection, err)
+		} else if !found {
+			return nil
+		}
+
+		_, err = indexes.DropOne(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to drop index \"%s\" on \"%s\" (%s)", name, collection, err)
+		}
+
+		return nil
+	}
+}
+
+// validateMigrations checks that the migrations' versions are positive and in
+// strictly increasing order.
+func validateMigrations(migrations []Migration) error {
+	previous := 0
+	for _, m := range migrations {
+		if m.Version <= previous {
+			return fmt.Errorf("migration %d is out of order", m.Version)
+		}
+
+		if m.Up == nil {
+			return fmt.Errorf("migration %d has nothing to apply", m.Version)
+		}
+
+		previous = m.Version
+	}
+
+	return nil
+}
+
+// MigrationStates returns the state of every migration, in order.
+func (db *DB) MigrationStates(ctx context.Context) ([]*MigrationState, error) {
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]*MigrationState, len(migrations))
+	for i, m := range migrations {
+		states[i] = &MigrationState{
+			Version:     m.Version,
+			Description: m.Description,
+		}
+
+		if d, ok := applied[m.Version]; ok {
+			states[i].AppliedAt = d.AppliedAt
+		}
+	}
+
+	return states, nil
+}
+
+// Migrate applies the pending migrations in order, and returns the ones it
+// applied. It stops at the first migration that fails, so that the next ones
+// are not applied to a schema they do not expect.
+func (db *DB) Migrate(ctx context.Context) ([]*MigrationState, error) {
+	err := validateMigrations(migrations)
+	if err != nil {
+		return nil, fmt.Errorf("db: invalid migrations (%s)", err)
+	}
+
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []*MigrationState
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		state, err := db.apply(ctx, m)
+		if err != nil {
+			return states, err
+		}
+
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+// apply applies the migration and records it.
+func (db *DB) apply(ctx context.Context, m Migration) (*MigrationState, error) {
+	ctx, cancel := context.WithTimeout(ctx, migrationTimeout)
+	defer cancel()
+
+	err := m.Up(ctx, db.database)
+	if err != nil {
+		return nil, fmt.Errorf("db: failed to apply migration %d (%s)", m.Version, err)
+	}
+
+	d := migrationDocument{
+		Version:     m.Version,
+		Description: m.Description,
+		AppliedAt:   time.Now().UTC(),
+	}
+
+	// Another instance might have recorded the migration in the meantime, in
+	// which case its record is kept.
+	filter := bson.D{{Key: "_id", Value: d.Version}}
+	update := bson.D{{Key: "$setOnInsert", Value: bson.D{
+		{Key: "description", Value: d.Description},
+		{Key: "appliedAt", Value: d.AppliedAt},
+	}}}
+	_, err = db.migrations.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return nil, fmt.Errorf("db: failed to record migration %d (%s)", m.Version, err)
+	}
+
+	return &MigrationState{
+		Version:     d.Version,
+		Description: d.Description,
+		AppliedAt:   d.AppliedAt,
+	}, nil
+}
+
+// appliedMigrations returns the migrations recorded as applied, by version.
+func (db *DB) appliedMigrations(ctx context.Context) (map[int]*migrationDocument, error) {
+	cur, err := db.migrations.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("db: failed to find applied migrations (%s)", err)
+	}
+	defer cur.Close(ctx)
+
+	applied := make(map[int]*migrationDocument)
+	for cur.Next(ctx) {
+		var d migrationDocument
+		err := cur.Decode(&d)
+		if err != nil {
+			return nil, fmt.Errorf("db: failed to decode applied migration (%s)", err)
+		}
+
+		applied[d.Version] = &d
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("db: failed to find applied migrations (%s)", err)
+	}
+
+	return applied, nil
+}
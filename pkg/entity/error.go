@@ -10,3 +10,9 @@ type ValidationError struct {
 func (e ValidationError) Error() string {
 	return e.msg
 }
+
+// NewValidationError creates a validation error with the given message, for
+// the values that are validated outside of entities.
+func NewValidationError(msg string) ValidationError {
+	return ValidationError{msg}
+}
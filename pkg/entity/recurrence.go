@@ -0,0 +1,292 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recurrence contains the rule of a search that is repeated, like a commute.
+// The rider wants to leave at the same time of day, on some days of the week,
+// between two dates.
+type Recurrence struct {
+	// Days represents the days of the week the rider travels on, by their
+	// English name, like "monday".
+	Days []string `json:"days"`
+
+	// TimeOfDay represents when the rider wants to leave, as "15:04".
+	TimeOfDay string `json:"timeOfDay"`
+
+	// TimeZone represents the IANA time zone of the time of day and dates,
+	// like "America/Toronto". UTC is used when it is empty.
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// StartDate and EndDate represent the first and last days the rider
+	// travels on, as "2006-01-02". Both are included.
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+
+	// parsed is the rule parsed when the recurrence is created or decoded, so
+	// that it is not parsed every time a trip is evaluated against it. It is
+	// nil when the rule is invalid, and is never changed afterwards, so the
+	// rule must not be changed either.
+	parsed *recurrence
+}
+
+// NewRecurrence creates a recurrence, and parses its rule once when it is
+// valid.
+func NewRecurrence(days []string, timeOfDay, timeZone, startDate, endDate string) *Recurrence {
+	r := &Recurrence{
+		Days:      days,
+		TimeOfDay: timeOfDay,
+		TimeZone:  timeZone,
+		StartDate: startDate,
+		EndDate:   endDate,
+	}
+	r.parsed, _ = r.parse()
+
+	return r
+}
+
+// UnmarshalJSON decodes the recurrence, and parses its rule once when it is
+// valid. An invalid rule is reported by Validate.
+func (r *Recurrence) UnmarshalJSON(data []byte) error {
+	type fields Recurrence
+
+	var f fields
+	err := json.Unmarshal(data, &f)
+	if err != nil {
+		return err
+	}
+
+	*r = *NewRecurrence(f.Days, f.TimeOfDay, f.TimeZone, f.StartDate, f.EndDate)
+
+	return nil
+}
+
+const (
+	// FormatTimeOfDay is used to parse the time of day of a recurrence.
+	FormatTimeOfDay = "15:04"
+
+	// FormatDate is used to parse the dates of a recurrence.
+	FormatDate = "2006-01-02"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// A recurrence is a parsed Recurrence, to compute its occurrences.
+type recurrence struct {
+	days     map[time.Weekday]bool
+	hour     int
+	minute   int
+	location *time.Location
+	start    time.Time
+	end      time.Time
+}
+
+// parse parses the recurrence's rule, and fails with a ValidationError when it
+// is not filled out correctly.
+func (r *Recurrence) parse() (*recurrence, error) {
+	if len(r.Days) == 0 {
+		return nil, ValidationError{"recurrence days must be specified"}
+	}
+
+	p := &recurrence{days: make(map[time.Weekday]bool), location: time.UTC}
+	for _, d := range r.Days {
+		w, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return nil, ValidationError{fmt.Sprintf("recurrence day \"%s\" is not a day of the week", d)}
+		}
+
+		p.days[w] = true
+	}
+
+	t, err := time.Parse(FormatTimeOfDay, r.TimeOfDay)
+	if err != nil {
+		return nil, ValidationError{fmt.Sprintf("recurrence timeOfDay \"%s\" must be formatted as HH:MM", r.TimeOfDay)}
+	}
+	p.hour, p.minute = t.Hour(), t.Minute()
+
+	// The local time zone is the one of the instance that parses the rule,
+	// which differs between instances.
+	if r.TimeZone == "Local" {
+		return nil, ValidationError{"recurrence timeZone must be an IANA time zone, not \"Local\""}
+	}
+
+	if r.TimeZone != "" {
+		p.location, err = loadLocation(r.TimeZone)
+		if err != nil {
+			return nil, ValidationError{fmt.Sprintf("recurrence timeZone \"%s\" is unknown", r.TimeZone)}
+		}
+	}
+
+	p.start, err = time.ParseInLocation(FormatDate, r.StartDate, p.location)
+	if err != nil {
+		return nil, ValidationError{fmt.Sprintf("recurrence startDate \"%s\" must be formatted as YYYY-MM-DD", r.StartDate)}
+	}
+
+	p.end, err = time.ParseInLocation(FormatDate, r.EndDate, p.location)
+	if err != nil {
+		return nil, ValidationError{fmt.Sprintf("recurrence endDate \"%s\" must be formatted as YYYY-MM-DD", r.EndDate)}
+	}
+
+	if p.end.Before(p.start) {
+		return nil, ValidationError{"recurrence endDate can't be before its startDate"}
+	}
+
+	return p, nil
+}
+
+// rule returns the parsed rule, and parses it when the recurrence was not
+// created by NewRecurrence or decoded.
+func (r *Recurrence) rule() (*recurrence, error) {
+	if r.parsed != nil {
+		return r.parsed, nil
+	}
+
+	return r.parse()
+}
+
+// Validate validates that the recurrence's required fields are filled out
+// correctly, and that the rider travels at least once.
+func (r *Recurrence) Validate() error {
+	p, err := r.parse()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := p.first(p.start); !ok {
+		return ValidationError{"recurrence has no occurrence between its startDate and endDate"}
+	}
+
+	return nil
+}
+
+// Next returns the first occurrence that is not before t, if there is one.
+func (r *Recurrence) Next(t time.Time) (time.Time, bool) {
+	p, err := r.rule()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return p.first(t)
+}
+
+// Last returns the last occurrence, if there is one.
+func (r *Recurrence) Last() (time.Time, bool) {
+	p, err := r.rule()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// Every day of the week is found in the last seven days, when they are
+	// in the date range.
+	for i, day := 0, p.end; i < 7 && !day.Before(p.start); i, day = i+1, day.AddDate(0, 0, -1) {
+		if p.days[day.Weekday()] {
+			return p.at(day), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Nearest returns the occurrence closest to t, if there is one.
+func (r *Recurrence) Nearest(t time.Time) (time.Time, bool) {
+	p, err := r.rule()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// The closest occurrence is at most a week away from the day of t, once
+	// it is brought within the date range.
+	center := p.date(t)
+	if center.Before(p.start) {
+		center = p.start
+	} else if center.After(p.end) {
+		center = p.end
+	}
+
+	var nearest time.Time
+	found := false
+	for day := center.AddDate(0, 0, -7); !day.After(center.AddDate(0, 0, 7)); day = day.AddDate(0, 0, 1) {
+		if day.Before(p.start) || day.After(p.end) || !p.days[day.Weekday()] {
+			continue
+		}
+
+		o := p.at(day)
+		if !found || absDuration(o.Sub(t)) < absDuration(nearest.Sub(t)) {
+			nearest, found = o, true
+		}
+	}
+
+	return nearest, found
+}
+
+// first returns the first occurrence that is not before t, if there is one.
+func (p *recurrence) first(t time.Time) (time.Time, bool) {
+	day := p.date(t)
+	if day.Before(p.start) {
+		day = p.start
+	}
+
+	for i := 0; i <= 7 && !day.After(p.end); i, day = i+1, day.AddDate(0, 0, 1) {
+		if !p.days[day.Weekday()] {
+			continue
+		}
+
+		if o := p.at(day); !o.Before(t) {
+			return o, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// date returns the day of t in the recurrence's time zone, at midnight.
+func (p *recurrence) date(t time.Time) time.Time {
+	y, m, d := t.In(p.location).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, p.location)
+}
+
+// at returns the occurrence on the day.
+func (p *recurrence) at(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), p.hour, p.minute, 0, 0, p.location)
+}
+
+// locations caches the time zones of recurrences, since they are loaded every
+// time a recurrence is parsed.
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if l, ok := locations.Load(name); ok {
+		return l.(*time.Location), nil
+	}
+
+	l, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+
+	locations.Store(name, l)
+
+	return l, nil
+}
+
+// absDuration returns the absolute value of the duration.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
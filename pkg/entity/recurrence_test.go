@@ -0,0 +1,119 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRecurrence(t *testing.T) {
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Weekdays at 7:30 in Toronto, from Monday, October 19 to Friday,
+	// November 6 2026. Daylight saving time ends on November 1.
+	var commute = Recurrence{
+		Days:      []string{"monday", "tuesday", "wednesday", "thursday", "Friday"},
+		TimeOfDay: "07:30",
+		TimeZone:  "America/Toronto",
+		StartDate: "2026-10-19",
+		EndDate:   "2026-11-06",
+	}
+
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, toronto)
+	}
+
+	t.Run("Should fail when the rule is not filled out correctly", func(t *testing.T) {
+		for name, change := range map[string]func(r *Recurrence){
+			"no days":          func(r *Recurrence) { r.Days = nil },
+			"unknown day":      func(r *Recurrence) { r.Days = []string{"someday"} },
+			"time of day":      func(r *Recurrence) { r.TimeOfDay = "7h30" },
+			"time zone":        func(r *Recurrence) { r.TimeZone = "Mars/Olympus_Mons" },
+			"local time zone":  func(r *Recurrence) { r.TimeZone = "Local" },
+			"start date":       func(r *Recurrence) { r.StartDate = "19/10/2026" },
+			"end before start": func(r *Recurrence) { r.EndDate = "2026-10-18" },
+			"no occurrence":    func(r *Recurrence) { r.Days, r.EndDate = []string{"sunday"}, "2026-10-24" },
+		} {
+			r := commute
+			change(&r)
+
+			if _, ok := r.Validate().(ValidationError); !ok {
+				t.Errorf("expected a validation error for %s", name)
+			}
+		}
+	})
+
+	t.Run("Should succeed when the rule is valid", func(t *testing.T) {
+		r := commute
+
+		err := r.Validate()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Should find the occurrence closest to a time", func(t *testing.T) {
+		for _, c := range []struct {
+			t        time.Time
+			expected time.Time
+		}{
+			{at(time.October, 21, 8, 0), at(time.October, 21, 7, 30)},
+			{at(time.October, 24, 12, 0), at(time.October, 23, 7, 30)},
+			{at(time.October, 25, 12, 0), at(time.October, 26, 7, 30)},
+			{at(time.October, 1, 7, 30), at(time.October, 19, 7, 30)},
+			{at(time.December, 25, 7, 30), at(time.November, 6, 7, 30)},
+			{at(time.November, 2, 7, 30), at(time.November, 2, 7, 30)},
+		} {
+			nearest, ok := commute.Nearest(c.t)
+			if !ok || !nearest.Equal(c.expected) {
+				t.Errorf("expected %s to be closest to %s, got %s", c.expected, c.t, nearest)
+			}
+		}
+	})
+
+	t.Run("Should find the next and the last occurrences", func(t *testing.T) {
+		next, ok := commute.Next(at(time.October, 23, 8, 0))
+		if !ok || !next.Equal(at(time.October, 26, 7, 30)) {
+			t.Errorf("expected the next occurrence on October 26, got %s", next)
+		}
+
+		_, ok = commute.Next(at(time.November, 6, 8, 0))
+		if ok {
+			t.Error("expected no occurrence after the last one")
+		}
+
+		last, ok := commute.Last()
+		if !ok || !last.Equal(at(time.November, 6, 7, 30)) {
+			t.Errorf("expected the last occurrence on November 6, got %s", last)
+		}
+	})
+
+	t.Run("Should decode the rule and report an invalid one when it is validated", func(t *testing.T) {
+		var r Recurrence
+		err := json.Unmarshal([]byte(`{"days":["monday"],"timeOfDay":"07:30","timeZone":"America/Toronto","startDate":"2026-10-19","endDate":"2026-10-19"}`), &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		last, ok := r.Last()
+		if !ok || !last.Equal(at(time.October, 19, 7, 30)) {
+			t.Errorf("expected the last occurrence on October 19, got %s", last)
+		}
+
+		err = json.Unmarshal([]byte(`{"days":["monday"],"timeOfDay":"7h30","startDate":"2026-10-19","endDate":"2026-10-19"}`), &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := r.Last(); ok {
+			t.Error("expected no occurrence for an invalid rule")
+		}
+
+		if _, ok := r.Validate().(ValidationError); !ok {
+			t.Error("expected a ValidationError for an invalid rule")
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// SavedSearch contains the information of a search that a rider saved, to be
+// notified when a matching trip is added instead of waiting for results.
+type SavedSearch struct {
+	ID     ID     `json:"id"`
+	UserID string `json:"userId"`
+
+	Filters *Filters `json:"filters"`
+
+	// CreatedAt represents when the search was saved.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Validate validates that the saved search's required fields are filled out
+// correctly.
+func (s *SavedSearch) Validate() error {
+	if s.Filters == nil {
+		return ValidationError{"missing filters"}
+	}
+
+	return s.Filters.Validate()
+}
@@ -2,6 +2,7 @@ package entity
 
 import (
 	"fmt"
+	"net/url"
 	"strconv"
 	"time"
 )
@@ -10,6 +11,14 @@ import (
 type Search struct {
 	ID      ID       `json:"id"`
 	Filters *Filters `json:"filters"`
+
+	// CallbackURL represents where the results are also delivered, for the
+	// integrations that cannot subscribe to the search's channel.
+	CallbackURL string `json:"callbackUrl,omitempty"`
+
+	// CallbackSecret represents the secret used to sign the results delivered
+	// to the callback URL, if any. It is never returned.
+	CallbackSecret string `json:"callbackSecret,omitempty"`
 }
 
 // Validate validates that the search's required fields are filled out
@@ -23,6 +32,25 @@ func (s *Search) Validate() error {
 		return err
 	}
 
+	if s.CallbackURL != "" {
+		if ValidateURL(s.CallbackURL) != nil {
+			return ValidationError{"callbackUrl must be an absolute http or https URL"}
+		}
+	} else if s.CallbackSecret != "" {
+		return ValidationError{"callbackSecret requires a callbackUrl"}
+	}
+
+	return nil
+}
+
+// ValidateURL validates that the URL is an absolute HTTP or HTTPS URL, that
+// events can be delivered to.
+func ValidateURL(URL string) error {
+	u, err := url.Parse(URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ValidationError{fmt.Sprintf("\"%s\" is not an HTTP or HTTPS URL", URL)}
+	}
+
 	return nil
 }
 
@@ -35,6 +63,20 @@ type Filters struct {
 	RadiusThresh *int      `json:"radiusThresh,ommitempty"`
 	Source       *Point    `json:"source"`
 	Destination  *Point    `json:"destination"`
+
+	// LeaveAfter and LeaveBefore represent the earliest and latest times the
+	// rider can leave at, and ArriveAfter and ArriveBefore the earliest and
+	// latest times they can arrive by, instead of LeaveAt or ArriveBy. Every
+	// bound is optional and included, but either LeaveBefore or ArriveBefore
+	// is required.
+	LeaveAfter   time.Time `json:"leaveAfter,omitempty"`
+	LeaveBefore  time.Time `json:"leaveBefore,omitempty"`
+	ArriveAfter  time.Time `json:"arriveAfter,omitempty"`
+	ArriveBefore time.Time `json:"arriveBefore,omitempty"`
+
+	// Recurrence represents when the rider travels when they search for the
+	// same trip repeatedly, instead of LeaveAt or ArriveBy.
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
 }
 
 const (
@@ -59,14 +101,37 @@ const (
 	// MinimumRadiusThresh represents the minimum value for radius threshold
 	MinimumRadiusThresh = 0
 
+	// DefaultRadiusThresh represents the radius threshold, in meters, used
+	// when none is specified
+	DefaultRadiusThresh = 5000
+
 	// FormatTimeRgx is used to convert time to string
 	FormatTimeRgx = "2006-01-02T15:04:05Z07:00"
 )
 
 // Validate validates that the filters's required fields are filled out correctly.
 func (f *Filters) Validate() error {
-	if f.LeaveAt.IsZero() && f.ArriveBy.IsZero() {
-		return ValidationError{"arriveBy or leaveAt must be specified"}
+	switch {
+	case f.Recurrence != nil:
+		if !f.LeaveAt.IsZero() || !f.ArriveBy.IsZero() || f.HasWindow() {
+			return ValidationError{"can't have a recurrence and a leaveAt, arriveBy or time window filter at the same time"}
+		}
+
+		err := f.Recurrence.Validate()
+		if err != nil {
+			return err
+		}
+	case f.HasWindow():
+		if !f.LeaveAt.IsZero() || !f.ArriveBy.IsZero() {
+			return ValidationError{"can't have a time window and a leaveAt or arriveBy filter at the same time"}
+		}
+
+		err := f.validateWindow()
+		if err != nil {
+			return err
+		}
+	case f.LeaveAt.IsZero() && f.ArriveBy.IsZero():
+		return ValidationError{"arriveBy, leaveAt, a time window or recurrence must be specified"}
 	}
 
 	if f.Source == nil || f.Destination == nil {
@@ -109,6 +174,70 @@ func (f *Filters) Validate() error {
 	return nil
 }
 
+// HasWindow returns whether the rider gave bounds to when they can leave or
+// arrive, instead of a single time.
+func (f *Filters) HasWindow() bool {
+	return !f.LeaveAfter.IsZero() || !f.LeaveBefore.IsZero() || !f.ArriveAfter.IsZero() || !f.ArriveBefore.IsZero()
+}
+
+// validateWindow validates that the bounds of the time window are consistent.
+func (f *Filters) validateWindow() error {
+	if f.LeaveBefore.IsZero() && f.ArriveBefore.IsZero() {
+		return ValidationError{"leaveBefore or arriveBefore must be specified with a time window"}
+	}
+
+	if !f.LeaveAfter.IsZero() && !f.LeaveBefore.IsZero() && f.LeaveAfter.After(f.LeaveBefore) {
+		return ValidationError{"leaveAfter can't be after leaveBefore"}
+	}
+
+	if !f.ArriveAfter.IsZero() && !f.ArriveBefore.IsZero() && f.ArriveAfter.After(f.ArriveBefore) {
+		return ValidationError{"arriveAfter can't be after arriveBefore"}
+	}
+
+	if !f.LeaveAfter.IsZero() && !f.ArriveBefore.IsZero() && !f.LeaveAfter.Before(f.ArriveBefore) {
+		return ValidationError{"leaveAfter must be before arriveBefore"}
+	}
+
+	return nil
+}
+
+// Radius returns the radius threshold in meters, or the default one when none
+// is specified.
+func (f *Filters) Radius() int {
+	if f.RadiusThresh == nil {
+		return DefaultRadiusThresh
+	}
+
+	return *f.RadiusThresh
+}
+
+// TravelAt returns when the rider wants to travel, either the time they want
+// to leave at or to arrive by, the latest time of their time window, or the
+// last occurrence of their recurrence.
+func (f *Filters) TravelAt() time.Time {
+	if f.Recurrence != nil {
+		last, _ := f.Recurrence.Last()
+		return last
+	}
+
+	// The rider may arrive after the latest time they leave at, so the latest
+	// of the bounds is used.
+	latest := f.LeaveBefore
+	if f.ArriveBefore.After(latest) {
+		latest = f.ArriveBefore
+	}
+
+	if !latest.IsZero() {
+		return latest
+	}
+
+	if !f.LeaveAt.IsZero() {
+		return f.LeaveAt
+	}
+
+	return f.ArriveBy
+}
+
 // ToMap returns list of query params
 func (f *Filters) ToMap() (map[string]string, error) {
 	mapArr := make(map[string]string)
@@ -125,6 +254,30 @@ func (f *Filters) ToMap() (map[string]string, error) {
 		mapArr["arriveBy"] = f.ArriveBy.Format(FormatTimeRgx)
 	}
 
+	if !f.LeaveAfter.IsZero() {
+		mapArr["leaveAfter"] = f.LeaveAfter.Format(FormatTimeRgx)
+	}
+
+	if !f.LeaveBefore.IsZero() {
+		mapArr["leaveBefore"] = f.LeaveBefore.Format(FormatTimeRgx)
+	}
+
+	if !f.ArriveAfter.IsZero() {
+		mapArr["arriveAfter"] = f.ArriveAfter.Format(FormatTimeRgx)
+	}
+
+	if !f.ArriveBefore.IsZero() {
+		mapArr["arriveBefore"] = f.ArriveBefore.Format(FormatTimeRgx)
+	}
+
+	// The trip service only knows about single trips, so the trips of the
+	// next occurrence are asked for.
+	if f.Recurrence != nil {
+		if next, ok := f.Recurrence.Next(time.Now()); ok {
+			mapArr["leaveAt"] = next.Format(FormatTimeRgx)
+		}
+	}
+
 	if f.RadiusThresh != nil {
 		mapArr["radiusThresh"] = strconv.Itoa(*f.RadiusThresh)
 	}
@@ -30,6 +30,26 @@ func TestSearchValidation(t *testing.T) {
 			t.Error(err)
 		}
 	})
+
+	t.Run("Should fail when the callback URL is not an absolute http URL", func(t *testing.T) {
+		for _, u := range []string{"partner.example.com/results", "ftp://partner.example.com", "https://"} {
+			s := search
+			s.CallbackURL = u
+
+			if _, ok := s.Validate().(ValidationError); !ok {
+				t.Errorf("expected a validation error for \"%s\"", u)
+			}
+		}
+	})
+
+	t.Run("Should fail when the callback secret has no URL", func(t *testing.T) {
+		s := search
+		s.CallbackSecret = "secret"
+
+		if _, ok := s.Validate().(ValidationError); !ok {
+			t.Fail()
+		}
+	})
 }
 
 func TestFiltersValidation(t *testing.T) {
@@ -47,4 +67,79 @@ func TestFiltersValidation(t *testing.T) {
 			t.Error(err)
 		}
 	})
+
+	t.Run("Should fail when a recurrence is given with a leaveAt", func(t *testing.T) {
+		f := filters
+		f.Recurrence = &Recurrence{Days: []string{"monday"}, TimeOfDay: "07:30", StartDate: "2026-10-19", EndDate: "2026-10-19"}
+
+		if _, ok := f.Validate().(ValidationError); !ok {
+			t.Fail()
+		}
+	})
+
+	t.Run("Should travel at the last occurrence of a recurrence", func(t *testing.T) {
+		f := filters
+		f.LeaveAt = time.Time{}
+		f.Recurrence = &Recurrence{Days: []string{"monday"}, TimeOfDay: "07:30", StartDate: "2026-10-19", EndDate: "2026-10-31"}
+
+		err := f.Validate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := time.Date(2026, time.October, 26, 7, 30, 0, 0, time.UTC)
+		if !f.TravelAt().Equal(expected) {
+			t.Errorf("expected to travel at %s, got %s", expected, f.TravelAt())
+		}
+	})
+
+	t.Run("Should fail when the time window is inconsistent", func(t *testing.T) {
+		morning := time.Date(2026, time.October, 23, 7, 0, 0, 0, time.UTC)
+
+		for name, change := range map[string]func(f *Filters){
+			"with leaveAt":    func(f *Filters) { f.LeaveBefore = morning },
+			"no latest bound": func(f *Filters) { f.LeaveAt, f.LeaveAfter = time.Time{}, morning },
+			"leave bounds reversed": func(f *Filters) {
+				f.LeaveAt, f.LeaveAfter, f.LeaveBefore = time.Time{}, morning.Add(time.Hour), morning
+			},
+			"arrive before leaving": func(f *Filters) { f.LeaveAt, f.LeaveAfter, f.ArriveBefore = time.Time{}, morning, morning },
+		} {
+			f := filters
+			change(&f)
+
+			if _, ok := f.Validate().(ValidationError); !ok {
+				t.Errorf("expected a validation error for %s", name)
+			}
+		}
+	})
+
+	t.Run("Should forward the time window and travel at its latest bound", func(t *testing.T) {
+		f := filters
+		f.LeaveAt = time.Time{}
+		f.LeaveAfter = time.Date(2026, time.October, 23, 7, 0, 0, 0, time.UTC)
+		f.LeaveBefore = time.Date(2026, time.October, 23, 9, 0, 0, 0, time.UTC)
+
+		err := f.Validate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !f.TravelAt().Equal(f.LeaveBefore) {
+			t.Errorf("expected to travel at %s, got %s", f.LeaveBefore, f.TravelAt())
+		}
+
+		f.ArriveBefore = time.Date(2026, time.October, 23, 10, 0, 0, 0, time.UTC)
+		if !f.TravelAt().Equal(f.ArriveBefore) {
+			t.Errorf("expected to travel at %s, got %s", f.ArriveBefore, f.TravelAt())
+		}
+
+		params, err := f.ToMap()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if params["leaveAfter"] != "2026-10-23T07:00:00Z" || params["leaveBefore"] != "2026-10-23T09:00:00Z" {
+			t.Errorf("expected the time window in the params, got %v", params)
+		}
+	})
 }
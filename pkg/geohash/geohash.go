@@ -0,0 +1,155 @@
+// Package geohash encodes geographic coordinates into geohash cells, which can
+// be used to index points and quickly find the ones that are close to each
+// other.
+package geohash
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// MinPrecision represents the precision of the largest cells.
+	MinPrecision = 1
+
+	// MaxPrecision represents the precision of the smallest cells.
+	MaxPrecision = 12
+
+	base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+	// metersPerDegree is the length of a degree of latitude on a sphere with
+	// the Earth's mean radius.
+	metersPerDegree = 111195.0
+)
+
+// Encode returns the geohash of the cell of the given precision that contains
+// the coordinates.
+func Encode(lat float64, lng float64, precision int) string {
+	precision = clampPrecision(precision)
+
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+
+	var hash strings.Builder
+	hash.Grow(precision)
+
+	even := true
+	bit, ch := 0, 0
+	for hash.Len() < precision {
+		if even {
+			mid := (minLng + maxLng) / 2
+			if lng >= mid {
+				ch = ch<<1 | 1
+				minLng = mid
+			} else {
+				ch = ch << 1
+				maxLng = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch = ch << 1
+				maxLat = mid
+			}
+		}
+
+		even = !even
+
+		bit++
+		if bit == 5 {
+			hash.WriteByte(base32[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return hash.String()
+}
+
+// CellSize returns the height and width, in degrees, of the cells of the given
+// precision.
+func CellSize(precision int) (float64, float64) {
+	precision = clampPrecision(precision)
+
+	bits := 5 * precision
+	lngBits := (bits + 1) / 2
+	latBits := bits / 2
+
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lngBits))
+}
+
+// Cover returns the geohashes of the cell of the given precision that contains
+// the coordinates and of the eight cells around it.
+//
+// When the precision is at most PrecisionForRadius(lat, radius), every point
+// within the radius of the coordinates is in one of those cells.
+func Cover(lat float64, lng float64, precision int) []string {
+	height, width := CellSize(precision)
+
+	seen := make(map[string]bool, 9)
+	cells := make([]string, 0, 9)
+	for i := -1; i <= 1; i++ {
+		cellLat := lat + float64(i)*height
+		if cellLat < -90 || cellLat > 90 {
+			continue
+		}
+
+		for j := -1; j <= 1; j++ {
+			cellLng := wrapLongitude(lng + float64(j)*width)
+
+			hash := Encode(cellLat, cellLng, precision)
+			if !seen[hash] {
+				seen[hash] = true
+				cells = append(cells, hash)
+			}
+		}
+	}
+
+	return cells
+}
+
+// PrecisionForRadius returns the highest precision whose cells are at least as
+// tall and as wide as the radius, in meters, around the given latitude.
+func PrecisionForRadius(lat float64, radius float64) int {
+	for precision := MaxPrecision; precision > MinPrecision; precision-- {
+		height, width := CellSize(precision)
+
+		// Cells get narrower as they get closer to the poles, so the width is
+		// measured on the cell's edge closest to the pole to stay on the safe
+		// side.
+		edgeLat := math.Min(math.Abs(lat)+height, 90)
+		widthMeters := width * metersPerDegree * math.Cos(edgeLat*math.Pi/180)
+
+		if height*metersPerDegree >= radius && widthMeters >= radius {
+			return precision
+		}
+	}
+
+	return MinPrecision
+}
+
+func clampPrecision(precision int) int {
+	if precision < MinPrecision {
+		return MinPrecision
+	}
+
+	if precision > MaxPrecision {
+		return MaxPrecision
+	}
+
+	return precision
+}
+
+func wrapLongitude(lng float64) float64 {
+	for lng < -180 {
+		lng += 360
+	}
+
+	for lng >= 180 {
+		lng -= 360
+	}
+
+	return lng
+}
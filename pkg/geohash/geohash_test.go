@@ -0,0 +1,50 @@
+package geohash
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	t.Run("Should encode coordinates to their known geohash", func(t *testing.T) {
+		hash := Encode(57.64911, 10.40744, 11)
+		if hash != "u4pruydqqvj" {
+			t.Errorf("expected \"u4pruydqqvj\", got \"%s\"", hash)
+		}
+	})
+}
+
+func TestCover(t *testing.T) {
+	t.Run("Should cover every point within the radius", func(t *testing.T) {
+		lat, lng := 45.4944494, -73.561703
+		radius := 5000.0
+
+		precision := PrecisionForRadius(lat, radius)
+
+		cells := make(map[string]bool)
+		for _, c := range Cover(lat, lng, precision) {
+			cells[c] = true
+		}
+
+		for angle := 0.0; angle < 2*math.Pi; angle += math.Pi / 16 {
+			pointLat := lat + radius/metersPerDegree*math.Sin(angle)
+			pointLng := lng + radius/(metersPerDegree*math.Cos(pointLat*math.Pi/180))*math.Cos(angle)
+
+			if !cells[Encode(pointLat, pointLng, precision)] {
+				t.Errorf("point (%f, %f) is not covered", pointLat, pointLng)
+			}
+		}
+	})
+
+	t.Run("Should wrap around the antimeridian", func(t *testing.T) {
+		for _, c := range Cover(0, 179.99, 5) {
+			if c == "" {
+				t.Fail()
+			}
+		}
+
+		if len(Cover(0, 179.99, 5)) != 9 {
+			t.Errorf("expected 9 cells")
+		}
+	})
+}
@@ -0,0 +1,157 @@
+// Package health reports whether the service's dependencies can be reached.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A Check is a function that probes a dependency. It returns an error when the
+// dependency cannot be reached, and must return when the context is done.
+type Check func(ctx context.Context) error
+
+// Status represents whether a dependency, or the service, is usable.
+type Status string
+
+const (
+	// StatusUp means that the dependency can be reached.
+	StatusUp Status = "up"
+
+	// StatusDown means that the dependency cannot be reached.
+	StatusDown Status = "down"
+
+	// StatusShuttingDown means that the service is shutting down and should
+	// no longer receive requests.
+	StatusShuttingDown Status = "shutting_down"
+)
+
+// A Result is the outcome of probing a single dependency.
+type Result struct {
+	Status   Status `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// A Report is the outcome of probing every dependency. Its status is up only
+// when every dependency that is not optional is up.
+type Report struct {
+	Status       Status             `json:"status"`
+	Dependencies map[string]*Result `json:"dependencies,omitempty"`
+}
+
+// DefaultCheckTimeout represents the default amount of time given to a
+// dependency to respond to a probe.
+const DefaultCheckTimeout = 2 * time.Second
+
+type namedCheck struct {
+	name     string
+	check    Check
+	optional bool
+}
+
+// A Checker probes the dependencies registered with it.
+type Checker struct {
+	timeout time.Duration
+
+	mu     sync.Mutex
+	checks []namedCheck
+}
+
+// NewChecker creates a checker that gives each dependency the given amount of
+// time to respond. A timeout of zero means DefaultCheckTimeout.
+func NewChecker(timeout time.Duration) (*Checker, error) {
+	if timeout < 0 {
+		return nil, fmt.Errorf("health.Checker: timeout cannot be negative")
+	} else if timeout == 0 {
+		timeout = DefaultCheckTimeout
+	}
+
+	return &Checker{timeout: timeout}, nil
+}
+
+// Register adds a dependency to probe under the given name.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, namedCheck{name, check, false})
+}
+
+// RegisterOptional adds a dependency to probe under the given name. It is
+// reported like the others, but does not bring the status of the report down,
+// since the service can still handle requests without it.
+func (c *Checker) RegisterOptional(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, namedCheck{name, check, true})
+}
+
+// Run probes every dependency at the same time and waits for all of them to
+// respond or time out.
+func (c *Checker) Run(ctx context.Context) *Report {
+	c.mu.Lock()
+	checks := c.checks
+	c.mu.Unlock()
+
+	results := make([]*Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.probe(ctx, check)
+		}(i, nc.check)
+	}
+	wg.Wait()
+
+	report := &Report{
+		Status:       StatusUp,
+		Dependencies: make(map[string]*Result, len(checks)),
+	}
+	for i, nc := range checks {
+		results[i].Optional = nc.optional
+		report.Dependencies[nc.name] = results[i]
+		if results[i].Status != StatusUp && !nc.optional {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+// probe runs a single check, giving up when the timeout elapses even if the
+// check does not return.
+func (c *Checker) probe(ctx context.Context, check Check) *Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("no response after %s", c.timeout)
+	}
+
+	result := &Result{
+		Status:   StatusUp,
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
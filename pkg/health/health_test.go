@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckerRun(t *testing.T) {
+	t.Run("Should be up when every dependency is up", func(t *testing.T) {
+		c, _ := NewChecker(time.Second)
+		c.Register("db", func(ctx context.Context) error { return nil })
+		c.Register("pubsub", func(ctx context.Context) error { return nil })
+
+		report := c.Run(context.Background())
+
+		if report.Status != StatusUp {
+			t.Errorf("expected status %s, got %s", StatusUp, report.Status)
+		}
+
+		if len(report.Dependencies) != 2 {
+			t.Errorf("expected 2 dependencies, got %d", len(report.Dependencies))
+		}
+	})
+
+	t.Run("Should be down when a dependency is down", func(t *testing.T) {
+		c, _ := NewChecker(time.Second)
+		c.Register("db", func(ctx context.Context) error { return nil })
+		c.Register("pubsub", func(ctx context.Context) error { return errors.New("disconnected") })
+
+		report := c.Run(context.Background())
+
+		if report.Status != StatusDown {
+			t.Errorf("expected status %s, got %s", StatusDown, report.Status)
+		}
+
+		if report.Dependencies["db"].Status != StatusUp {
+			t.Errorf("expected db to be up, got %s", report.Dependencies["db"].Status)
+		}
+
+		if report.Dependencies["pubsub"].Error != "disconnected" {
+			t.Errorf("expected pubsub error to be reported, got %q", report.Dependencies["pubsub"].Error)
+		}
+	})
+
+	t.Run("Should be up when only an optional dependency is down", func(t *testing.T) {
+		c, _ := NewChecker(time.Second)
+		c.Register("db", func(ctx context.Context) error { return nil })
+		c.RegisterOptional("trips", func(ctx context.Context) error { return errors.New("unavailable") })
+
+		report := c.Run(context.Background())
+
+		if report.Status != StatusUp {
+			t.Errorf("expected status %s, got %s", StatusUp, report.Status)
+		}
+
+		if report.Dependencies["trips"].Status != StatusDown || !report.Dependencies["trips"].Optional {
+			t.Errorf("expected trips to be reported as an optional dependency that is down, got %+v", report.Dependencies["trips"])
+		}
+	})
+
+	t.Run("Should be down when a dependency does not respond in time", func(t *testing.T) {
+		c, _ := NewChecker(10 * time.Millisecond)
+
+		block := make(chan struct{})
+		defer close(block)
+		c.Register("trips", func(ctx context.Context) error {
+			<-block
+			return nil
+		})
+
+		start := time.Now()
+		report := c.Run(context.Background())
+
+		if time.Since(start) > time.Second {
+			t.Errorf("expected the probe to time out, it took %s", time.Since(start))
+		}
+
+		if report.Dependencies["trips"].Status != StatusDown {
+			t.Errorf("expected trips to be down, got %s", report.Dependencies["trips"].Status)
+		}
+	})
+}
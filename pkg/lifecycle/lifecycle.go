@@ -0,0 +1,97 @@
+// Package lifecycle coordinates the graceful shutdown of the service's
+// components.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A Hook is a function that shuts a component down. It must return when the
+// context is done, even if the component did not finish shutting down.
+type Hook func(ctx context.Context) error
+
+type namedHook struct {
+	name string
+	hook Hook
+}
+
+// A Manager runs hooks to shut components down gracefully, one after the
+// other, in the order they were registered.
+type Manager struct {
+	logger       *slog.Logger
+	mu           sync.Mutex
+	hooks        []namedHook
+	shuttingDown int32
+	once         sync.Once
+	err          error
+}
+
+// NewManager creates a lifecycle manager without any hooks, which logs the
+// progress of the shutdown with the given logger.
+func NewManager(logger *slog.Logger) *Manager {
+	return &Manager{logger: logger}
+}
+
+// OnShutdown registers a hook to run when shutting down. Hooks are run in the
+// order they were registered, so components that depend on others must be
+// registered first.
+func (m *Manager) OnShutdown(name string, hook Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hooks = append(m.hooks, namedHook{name, hook})
+}
+
+// ShuttingDown returns whether the shutdown has started.
+func (m *Manager) ShuttingDown() bool {
+	return atomic.LoadInt32(&m.shuttingDown) == 1
+}
+
+// WaitForSignal blocks until one of the given signals is received.
+func (m *Manager) WaitForSignal(signals ...os.Signal) os.Signal {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	defer signal.Stop(c)
+
+	return <-c
+}
+
+// Shutdown runs every hook, even when some of them fail, and returns an error
+// listing the ones that failed. The context's deadline applies to all of the
+// hooks, not to each of them.
+//
+// Only the first call runs the hooks. Subsequent calls return the same
+// result.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.once.Do(func() {
+		atomic.StoreInt32(&m.shuttingDown, 1)
+
+		m.mu.Lock()
+		hooks := m.hooks
+		m.mu.Unlock()
+
+		var failures []string
+		for _, h := range hooks {
+			m.logger.InfoContext(ctx, "shutting down", "component", h.name)
+
+			err := h.hook(ctx)
+			if err != nil {
+				m.logger.ErrorContext(ctx, "failed to shut down", "component", h.name, "error", err)
+				failures = append(failures, fmt.Sprintf("%s (%s)", h.name, err))
+			}
+		}
+
+		if len(failures) > 0 {
+			m.err = fmt.Errorf("lifecycle: failed to shut down %s", strings.Join(failures, ", "))
+		}
+	})
+
+	return m.err
+}
@@ -0,0 +1,55 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+)
+
+func TestManagerShutdown(t *testing.T) {
+	t.Run("Should run every hook in order even when one fails", func(t *testing.T) {
+		m := NewManager(logging.Discard())
+
+		var order []string
+		m.OnShutdown("first", func(ctx context.Context) error {
+			order = append(order, "first")
+			return errors.New("oops")
+		})
+		m.OnShutdown("second", func(ctx context.Context) error {
+			if !m.ShuttingDown() {
+				t.Error("expected the manager to be shutting down")
+			}
+
+			order = append(order, "second")
+			return nil
+		})
+
+		err := m.Shutdown(context.Background())
+		if err == nil {
+			t.Error("expected an error")
+		}
+
+		if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+			t.Errorf("unexpected order %v", order)
+		}
+	})
+
+	t.Run("Should only run the hooks once", func(t *testing.T) {
+		m := NewManager(logging.Discard())
+
+		runs := 0
+		m.OnShutdown("hook", func(ctx context.Context) error {
+			runs++
+			return nil
+		})
+
+		_ = m.Shutdown(context.Background())
+		_ = m.Shutdown(context.Background())
+
+		if runs != 1 {
+			t.Errorf("expected 1 run, got %d", runs)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+// Package logging creates the structured logger used throughout the service.
+//
+// Entries are written as JSON, one per line. The request, search and trip IDs
+// found in the context given to the logger are added to every entry, so that
+// the entries related to a request or a search can be found easily, along with
+// the ID of the trace, if there is one.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"azure.com/ecovo/trip-search-service/pkg/requestid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey string
+
+const (
+	searchIDContextKey = contextKey("searchId")
+	tripIDContextKey   = contextKey("tripId")
+)
+
+// New creates a logger that writes JSON entries of the given level or above
+// to w.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(&contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// Discard creates a logger that drops every entry.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// ParseLevel converts the name of a level (debug, info, warn or error) to a
+// level. An empty name means info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("logging: unknown level \"%s\"", name)
+	}
+}
+
+// WithSearchID returns a copy of the context that tags the entries logged with
+// it with the given search ID.
+func WithSearchID(ctx context.Context, searchID string) context.Context {
+	return context.WithValue(ctx, searchIDContextKey, searchID)
+}
+
+// WithTripID returns a copy of the context that tags the entries logged with
+// it with the given trip ID.
+func WithTripID(ctx context.Context, tripID string) context.Context {
+	return context.WithValue(ctx, tripIDContextKey, tripID)
+}
+
+// A contextHandler adds the IDs found in the context to the entries before
+// handing them to the underlying handler.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID, err := requestid.FromContext(ctx); err == nil {
+		r.AddAttrs(slog.String("requestId", requestID))
+	}
+
+	if searchID, ok := ctx.Value(searchIDContextKey).(string); ok {
+		r.AddAttrs(slog.String("searchId", searchID))
+	}
+
+	if tripID, ok := ctx.Value(tripIDContextKey).(string); ok {
+		r.AddAttrs(slog.String("tripId", tripID))
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("traceId", sc.TraceID().String()), slog.String("spanId", sc.SpanID().String()))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"azure.com/ecovo/trip-search-service/pkg/requestid"
+)
+
+func TestLogger(t *testing.T) {
+	t.Run("Should tag entries with the IDs found in the context", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := New(&buf, slog.LevelInfo)
+
+		ctx := context.WithValue(context.Background(), requestid.RequestIDContextKey, "request")
+		ctx = WithSearchID(ctx, "search")
+		ctx = WithTripID(ctx, "trip")
+
+		logger.InfoContext(ctx, "hello")
+
+		var entry map[string]interface{}
+		err := json.Unmarshal(buf.Bytes(), &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for key, expected := range map[string]string{"requestId": "request", "searchId": "search", "tripId": "trip"} {
+			if entry[key] != expected {
+				t.Errorf("expected %s to be %q, got %v", key, expected, entry[key])
+			}
+		}
+	})
+
+	t.Run("Should drop entries below the level", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := New(&buf, slog.LevelWarn)
+
+		logger.Info("hello")
+
+		if buf.Len() != 0 {
+			t.Errorf("expected no entry, got %s", buf.String())
+		}
+	})
+}
+
+func TestParseLevel(t *testing.T) {
+	t.Run("Should default to info", func(t *testing.T) {
+		level, err := ParseLevel("")
+		if err != nil || level != slog.LevelInfo {
+			t.Errorf("expected info, got %s (%v)", level, err)
+		}
+	})
+
+	t.Run("Should reject unknown levels", func(t *testing.T) {
+		_, err := ParseLevel("verbose")
+		if err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
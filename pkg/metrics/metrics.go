@@ -0,0 +1,119 @@
+// Package metrics defines the Prometheus metrics exposed by the service.
+//
+// The metrics are registered with the default registry as soon as the package
+// is imported, so they can be updated from anywhere and exposed with
+// promhttp.Handler.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "trip_search"
+
+// The reasons for which a trip is rejected by a search.
+const (
+	// ReasonNone is used when a trip matches a search.
+	ReasonNone = "none"
+
+	// ReasonSourceTooFar is used when the trip's route does not come close
+	// enough to the search's source.
+	ReasonSourceTooFar = "source_too_far"
+
+	// ReasonDestinationTooFar is used when the trip's route does not come
+	// close enough to the search's destination.
+	ReasonDestinationTooFar = "destination_too_far"
+
+	// ReasonNoOccurrence is used when the trip does not leave close enough to
+	// any occurrence of a recurring search.
+	ReasonNoOccurrence = "no_occurrence"
+
+	// ReasonOutsideWindow is used when the trip does not leave or arrive
+	// within the time window of a search.
+	ReasonOutsideWindow = "outside_window"
+)
+
+// EventOther is used to count the trip events whose type is unknown, so that
+// whoever publishes on the trips channel cannot create new series.
+const EventOther = "other"
+
+var (
+	// ActiveSearches represents the number of searches whose worker runs on
+	// this instance.
+	ActiveSearches = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_searches",
+		Help:      "Number of searches whose worker runs on this instance.",
+	})
+
+	// PoolQueueDepth represents the number of trips waiting to be evaluated
+	// by the worker pool.
+	PoolQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pool_queue_depth",
+		Help:      "Number of trips waiting to be evaluated by the worker pool.",
+	})
+
+	// TripsReceived counts the trip events received from the trips channel,
+	// by event type.
+	TripsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trips_received_total",
+		Help:      "Trip events received from the trips channel, by event type.",
+	}, []string{"event"})
+
+	// TripEvaluations counts the trips evaluated for a search, by result
+	// (matched or rejected) and rejection reason.
+	TripEvaluations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trip_evaluations_total",
+		Help:      "Trips evaluated for a search, by result and rejection reason.",
+	}, []string{"result", "reason"})
+
+	// RouteLookupDuration observes how long it takes to get the route
+	// followed by a trip, by result (ok or error).
+	RouteLookupDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "route_lookup_duration_seconds",
+		Help:      "Time taken to get the route followed by a trip, by result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	// TripServiceDuration observes how long requests to the trip service
+	// take, by HTTP status code, or "error" when no response was received.
+	TripServiceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "trip_service_request_duration_seconds",
+		Help:      "Time taken by requests to the trip service, by status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"code"})
+
+	// PublishDuration observes how long it takes to publish a message on
+	// Ably, by result (ok or error).
+	PublishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "publish_duration_seconds",
+		Help:      "Time taken to publish a message on Ably, by result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	// HTTPRequestDuration observes how long the service takes to handle
+	// requests, by method, route template and status code.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+)
+
+// Result returns the label used for the result of an operation that failed
+// when the error is not nil.
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
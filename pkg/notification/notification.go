@@ -0,0 +1,67 @@
+// Package notification tells users about events that happened while they
+// were not looking for them, like a trip matching one of their saved searches
+// being added.
+//
+// Notifications are sent through notifiers, so that new ways to reach users
+// can be plugged in without changing what triggers the notifications.
+package notification
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+)
+
+// EventSavedSearchMatched represents the event where a trip matching a saved
+// search was added.
+const EventSavedSearchMatched = "SAVED_SEARCH_MATCHED"
+
+// A Notification represents an event that a user must be told about.
+type Notification struct {
+	// Type represents what the notification is about.
+	Type string `json:"type"`
+
+	// UserID represents the unique identifier of the user to notify.
+	UserID string `json:"userId"`
+
+	// SavedSearchID represents the unique identifier of the saved search the
+	// trip matched.
+	SavedSearchID entity.ID `json:"savedSearchId"`
+
+	// Trip represents the trip that matched the saved search.
+	Trip *entity.Trip `json:"trip"`
+
+	// CreatedAt represents when the notification was created.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Notifier is an interface representing the ability to deliver notifications
+// to users.
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// A MultiNotifier is a notifier that delivers notifications through several
+// notifiers.
+type MultiNotifier struct {
+	notifiers []Notifier
+}
+
+// NewMultiNotifier creates a notifier that delivers notifications through
+// every given notifier.
+func NewMultiNotifier(notifiers ...Notifier) Notifier {
+	return &MultiNotifier{notifiers}
+}
+
+// Notify delivers the notification through every notifier, even when some of
+// them fail, and returns their errors joined.
+func (m *MultiNotifier) Notify(ctx context.Context, n *Notification) error {
+	var errs []error
+	for _, notifier := range m.notifiers {
+		errs = append(errs, notifier.Notify(ctx, n))
+	}
+
+	return errors.Join(errs...)
+}
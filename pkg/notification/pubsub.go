@@ -0,0 +1,46 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+
+	"azure.com/ecovo/trip-search-service/pkg/pubsub"
+	"azure.com/ecovo/trip-search-service/pkg/pubsub/subscription"
+)
+
+// TopicPrefix represents the prefix of the topics notifications are published
+// on. Each user has their own topic, named after their unique identifier.
+const TopicPrefix = "notifications:"
+
+// A PubSubNotifier is a notifier that publishes notifications on the topic of
+// the user to notify.
+type PubSubNotifier struct {
+	pubSub pubsub.UseCase
+}
+
+// NewPubSubNotifier creates a notifier that publishes notifications through
+// the given pub/sub service.
+func NewPubSubNotifier(pubSub pubsub.UseCase) (Notifier, error) {
+	if pubSub == nil {
+		return nil, fmt.Errorf("notification.PubSubNotifier: pub/sub is nil")
+	}
+
+	return &PubSubNotifier{pubSub}, nil
+}
+
+// Notify publishes the notification on the user's topic.
+func (n *PubSubNotifier) Notify(ctx context.Context, notification *Notification) error {
+	if notification == nil || notification.UserID == "" {
+		return fmt.Errorf("notification.PubSubNotifier: notification has no user")
+	}
+
+	topic := TopicPrefix + notification.UserID
+
+	sub, err := n.pubSub.Subscribe(topic)
+	if err != nil {
+		return fmt.Errorf("notification.PubSubNotifier: failed to subscribe to \"%s\" (%s)", topic, err)
+	}
+	defer n.pubSub.Unsubscribe(topic)
+
+	return sub.Publish(ctx, &subscription.Message{Type: notification.Type, Data: notification})
+}
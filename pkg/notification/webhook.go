@@ -0,0 +1,39 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/webhook"
+)
+
+// A WebhookNotifier is a notifier that delivers every notification to a single
+// webhook, which is in charge of reaching the users.
+type WebhookNotifier struct {
+	client *webhook.Client
+	url    string
+	secret string
+}
+
+// NewWebhookNotifier creates a notifier that delivers notifications to the
+// webhook at the URL with the client, signed with the secret when there is
+// one.
+func NewWebhookNotifier(client *webhook.Client, URL string, secret string) (Notifier, error) {
+	if client == nil {
+		return nil, fmt.Errorf("notification.WebhookNotifier: client is nil")
+	}
+
+	err := entity.ValidateURL(URL)
+	if err != nil {
+		return nil, fmt.Errorf("notification.WebhookNotifier: %s", err)
+	}
+
+	return &WebhookNotifier{client, URL, secret}, nil
+}
+
+// Notify delivers the notification to the webhook.
+func (n *WebhookNotifier) Notify(ctx context.Context, notification *Notification) error {
+	_, err := n.client.Send(ctx, n.url, n.secret, notification.Type, notification)
+	return err
+}
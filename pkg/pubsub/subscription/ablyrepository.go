@@ -1,8 +1,10 @@
 package subscription
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/ably/ably-go/ably"
 )
@@ -12,6 +14,7 @@ import (
 // realtime channels.
 type AblyRepository struct {
 	client              *ably.RealtimeClient
+	mu                  sync.Mutex
 	subcriptionsByTopic map[string][]Subscription
 }
 
@@ -52,6 +55,17 @@ func (r *AblyRepository) Delete(topic string) {
 	r.removeSubscriptionFromTopic(topic)
 }
 
+// Ping checks that the connection to Ably is established. Ably reconnects on
+// its own, so it only reports the state of the connection.
+func (r *AblyRepository) Ping(ctx context.Context) error {
+	state := r.client.Connection.State()
+	if state != ably.StateConnConnected {
+		return fmt.Errorf("subscription.AblyRepository: connection is %s", state)
+	}
+
+	return nil
+}
+
 func (r *AblyRepository) addSubscriptionToTopic(sub Subscription) error {
 	if sub == nil {
 		return fmt.Errorf("pubsub.Service: cannot add nil subscription to topic")
@@ -59,6 +73,9 @@ func (r *AblyRepository) addSubscriptionToTopic(sub Subscription) error {
 
 	topic := sub.Topic()
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	subs, ok := r.subcriptionsByTopic[topic]
 	if !ok {
 		subs = make([]Subscription, 0, 1)
@@ -74,6 +91,9 @@ func (r *AblyRepository) removeSubscriptionFromTopic(topic string) {
 		return
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	subs, ok := r.subcriptionsByTopic[topic]
 	if !ok {
 		return
@@ -1,14 +1,25 @@
 package subscription
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
+	"azure.com/ecovo/trip-search-service/pkg/metrics"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
 	"azure.com/ecovo/trip-search-service/pkg/trip"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ably/proto"
 )
 
+var tracer = otel.Tracer("azure.com/ecovo/trip-search-service/pkg/pubsub/subscription")
+
 // An AblySubscription represents a subscription to an Ably realtime channel.
 type AblySubscription struct {
 	// Channel represents the Ably realtime channel associated to the
@@ -25,18 +36,47 @@ func NewAblySubscription(channel *ably.RealtimeChannel) (Subscription, error) {
 	return &AblySubscription{channel}, nil
 }
 
-// Publish sends a message on the subscription's topic.
-func (s *AblySubscription) Publish(msg *Message) error {
+// Publish sends a message on the subscription's topic. The trace context is
+// added to the message's metadata, which is sent in the headers of the Ably
+// message's extras.
+func (s *AblySubscription) Publish(ctx context.Context, msg *Message) (err error) {
 	if msg == nil {
 		return fmt.Errorf("subscription.AblySubscription [topic=%s]: message cannot be nil", s.Topic())
 	}
 
+	ctx, span := tracer.Start(ctx, s.Topic()+" publish", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("ably"),
+			semconv.MessagingDestinationName(s.Topic()),
+			semconv.MessagingOperationTypePublish,
+		))
+	defer func() { tracing.End(span, err) }()
+
 	payload, err := json.Marshal(msg.Data)
 	if err != nil {
 		return fmt.Errorf("subscription.AblySubscription [topic=%s]: failed to marshal message (%s)", s.Topic(), err)
 	}
 
-	res, err := s.channel.Publish(msg.Type, string(payload))
+	headers := make(map[string]string, len(msg.Metadata))
+	for key, value := range msg.Metadata {
+		headers[key] = value
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+
+	start := time.Now()
+
+	err = s.publish(&proto.Message{
+		Name:   msg.Type,
+		Data:   string(payload),
+		Extras: map[string]interface{}{"headers": headers},
+	})
+	metrics.PublishDuration.WithLabelValues(metrics.Result(err)).Observe(time.Since(start).Seconds())
+
+	return err
+}
+
+func (s *AblySubscription) publish(msg *proto.Message) error {
+	res, err := s.channel.PublishAll([]*proto.Message{msg})
 	if err != nil {
 		return fmt.Errorf("subscription.AblySubscription [topic=%s]: failed to publish message (%s)", s.Topic(), err)
 	}
@@ -58,7 +98,7 @@ func (s *AblySubscription) Subscribe(callback Callback) error {
 
 	go func() {
 		for msg := range sub.MessageChannel() {
-			callback(&Message{Type: msg.Name, Data: msg.Data})
+			callback(&Message{Type: msg.Name, Data: msg.Data, Metadata: headers(msg)})
 		}
 	}()
 
@@ -69,3 +109,21 @@ func (s *AblySubscription) Subscribe(callback Callback) error {
 func (s *AblySubscription) Topic() string {
 	return s.channel.Name
 }
+
+// headers returns the headers found in the extras of an Ably message.
+func headers(msg *proto.Message) map[string]string {
+	h := make(map[string]string)
+
+	raw, ok := msg.Extras["headers"].(map[string]interface{})
+	if !ok {
+		return h
+	}
+
+	for key, value := range raw {
+		if s, ok := value.(string); ok {
+			h[key] = s
+		}
+	}
+
+	return h
+}
@@ -7,4 +7,7 @@ type Message struct {
 	Type string
 	// Data represents the message's content.
 	Data interface{}
+	// Metadata represents headers sent along with the message, like the trace
+	// context.
+	Metadata map[string]string
 }
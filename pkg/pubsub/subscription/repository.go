@@ -1,8 +1,13 @@
 package subscription
 
+import "context"
+
 // A Repository is an interface representing the ability to perform CRUD
 // operations on subscriptions.
 type Repository interface {
 	Create(topic string) (Subscription, error)
 	Delete(topic string)
+
+	// Ping checks that the underlying pub/sub system can be reached.
+	Ping(ctx context.Context) error
 }
@@ -1,12 +1,14 @@
 package subscription
 
+import "context"
+
 // Callback is a callback function for the subcsription
 type Callback func(msg *Message)
 
 // A Subscription is an interface representing the ability to publish and
 // listen for messages on a given topic.
 type Subscription interface {
-	Publish(msg *Message) error
+	Publish(ctx context.Context, msg *Message) error
 	Subscribe(callback Callback) error
 	Topic() string
 }
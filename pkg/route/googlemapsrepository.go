@@ -3,7 +3,11 @@ package route
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"strconv"
+	"time"
 
 	"azure.com/ecovo/trip-search-service/pkg/entity"
 	"googlemaps.github.io/maps"
@@ -11,9 +15,18 @@ import (
 
 // GoogleMapsRepository structure
 type GoogleMapsRepository struct {
-	client *maps.Client
+	client     *maps.Client
+	httpClient *http.Client
 }
 
+// directionsTimeout represents how long to wait for Google Maps to respond
+// before giving up on getting a route.
+const directionsTimeout = 10 * time.Second
+
+// directionsURL represents the endpoint of the Directions API, which is probed
+// to check that Google Maps can be reached.
+const directionsURL = "https://maps.googleapis.com/maps/api/directions/json"
+
 // NewGoogleMapsRepository creates the repository
 func NewGoogleMapsRepository(client *maps.Client) (Repository, error) {
 	if client == nil {
@@ -21,12 +34,13 @@ func NewGoogleMapsRepository(client *maps.Client) (Repository, error) {
 	}
 
 	return &GoogleMapsRepository{
-		client: client,
+		client:     client,
+		httpClient: &http.Client{},
 	}, nil
 }
 
 // GetRoute returns the route generated by Google Maps based on a trip
-func (gr *GoogleMapsRepository) GetRoute(t *entity.Trip) (maps.Route, error) {
+func (gr *GoogleMapsRepository) GetRoute(ctx context.Context, t *entity.Trip) (maps.Route, error) {
 	var wp = make([]string, len(t.Stops))
 	for i, s := range t.Stops {
 		wp[i] = s.Point.String()
@@ -45,7 +59,10 @@ func (gr *GoogleMapsRepository) GetRoute(t *entity.Trip) (maps.Route, error) {
 		return maps.Route{}, fmt.Errorf("trip.GoogleMapsRepository: leaveAt must be specified")
 	}
 
-	r, _, err := gr.client.Directions(context.Background(), dr)
+	ctx, cancel := context.WithTimeout(ctx, directionsTimeout)
+	defer cancel()
+
+	r, _, err := gr.client.Directions(ctx, dr)
 	if err != nil {
 		return maps.Route{}, fmt.Errorf("trip.GoogleMapsRepository: error getting directions, %s", err)
 	}
@@ -56,3 +73,27 @@ func (gr *GoogleMapsRepository) GetRoute(t *entity.Trip) (maps.Route, error) {
 
 	return maps.Route{}, fmt.Errorf("trip.GoogleMapsRepository: no trips found in google map repository")
 }
+
+// Ping checks that Google Maps can be reached. The probe is not authenticated,
+// so that it is not billed, which means that an invalid API key goes unnoticed.
+func (gr *GoogleMapsRepository) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", directionsURL, nil)
+	if err != nil {
+		return fmt.Errorf("route.GoogleMapsRepository: failed to create request (%s)", err)
+	}
+
+	resp, err := gr.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("route.GoogleMapsRepository: failed request to Google Maps (%s)", err)
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("route.GoogleMapsRepository: failed request to Google Maps (HTTP %d)", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -1,11 +1,16 @@
 package route
 
 import (
+	"context"
+
 	"azure.com/ecovo/trip-search-service/pkg/entity"
 	"googlemaps.github.io/maps"
 )
 
 // Repository interface
 type Repository interface {
-	GetRoute(t *entity.Trip) (maps.Route, error)
+	GetRoute(ctx context.Context, t *entity.Trip) (maps.Route, error)
+
+	// Ping checks that the route provider can be reached.
+	Ping(ctx context.Context) error
 }
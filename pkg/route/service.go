@@ -1,13 +1,22 @@
 package route
 
 import (
+	"context"
+	"time"
+
 	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/metrics"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"googlemaps.github.io/maps"
 )
 
+var tracer = otel.Tracer("azure.com/ecovo/trip-search-service/pkg/route")
+
 // UseCase interface
 type UseCase interface {
-	GetRoute(t *entity.Trip) (maps.Route, error)
+	GetRoute(ctx context.Context, t *entity.Trip) (maps.Route, error)
 }
 
 // Service structure
@@ -21,6 +30,17 @@ func NewService(repo Repository) UseCase {
 }
 
 // GetRoute returns google maps route for a trip
-func (s *Service) GetRoute(t *entity.Trip) (maps.Route, error) {
-	return s.repo.GetRoute(t)
+func (s *Service) GetRoute(ctx context.Context, t *entity.Trip) (maps.Route, error) {
+	ctx, span := tracer.Start(ctx, "route.Service.GetRoute")
+	if t != nil {
+		span.SetAttributes(attribute.String("trip.id", t.ID.Hex()))
+	}
+
+	start := time.Now()
+
+	r, err := s.repo.GetRoute(ctx, t)
+	metrics.RouteLookupDuration.WithLabelValues(metrics.Result(err)).Observe(time.Since(start).Seconds())
+	tracing.End(span, err)
+
+	return r, err
 }
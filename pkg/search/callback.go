@@ -0,0 +1,47 @@
+package search
+
+import (
+	"context"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/pubsub/subscription"
+	"azure.com/ecovo/trip-search-service/pkg/webhook"
+)
+
+// maxDeliveries represents how many of the latest deliveries to a search's
+// callback URL are returned.
+const maxDeliveries = 100
+
+// A ResultEvent is the body of the events delivered to a search's callback
+// URL. The type of the event is also sent in the webhook.EventHeader.
+type ResultEvent struct {
+	SearchID entity.ID   `json:"searchId"`
+	Type     string      `json:"type"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// A callbackSubscription is a subscription that also delivers the events
+// published to it to the search's callback URL.
+//
+// The events are delivered in the background, so that a slow receiver does
+// not hold back the search's worker, and in no particular order.
+type callbackSubscription struct {
+	subscription.Subscription
+	webhooks *webhook.Dispatcher
+	search   *entity.Search
+}
+
+func (c *callbackSubscription) Publish(ctx context.Context, msg *subscription.Message) error {
+	err := c.Subscription.Publish(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	c.webhooks.Dispatch(ctx, c.search.ID.Hex(), c.search.CallbackURL, c.search.CallbackSecret, msg.Type, &ResultEvent{
+		SearchID: c.search.ID,
+		Type:     msg.Type,
+		Data:     msg.Data,
+	})
+
+	return nil
+}
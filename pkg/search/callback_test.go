@@ -0,0 +1,90 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/pubsub/subscription"
+	"azure.com/ecovo/trip-search-service/pkg/webhook"
+)
+
+func TestCallbackSubscription(t *testing.T) {
+	t.Run("Should deliver the published results signed to the callback URL", func(t *testing.T) {
+		received := make(chan *ResultEvent, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if !webhook.Verify("secret", body, r.Header.Get(webhook.SignatureHeader)) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			var e ResultEvent
+			err = json.Unmarshal(body, &e)
+			if err != nil {
+				t.Error(err)
+			}
+			received <- &e
+		}))
+		defer server.Close()
+
+		client, err := webhook.NewClient(&webhook.ClientConfig{AllowPrivateNetworks: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		log, err := webhook.NewMemoryDeliveryLog()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		webhooks, err := webhook.NewDispatcher(client, log, 0, logging.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		search := &entity.Search{ID: "search", CallbackURL: server.URL, CallbackSecret: "secret"}
+		sub := newFakeSubscription("search:search")
+		c := &callbackSubscription{sub, webhooks, search}
+
+		err = c.Publish(context.Background(), &subscription.Message{Type: EventAddResult, Data: &entity.Trip{ID: "trip"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = webhooks.Shutdown(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sub.count() != 1 {
+			t.Errorf("expected the result to be published, got %d messages", sub.count())
+		}
+
+		select {
+		case e := <-received:
+			if e.SearchID != search.ID || e.Type != EventAddResult {
+				t.Errorf("expected %s for search %s, got %+v", EventAddResult, search.ID, e)
+			}
+		default:
+			t.Fatal("expected the result to be delivered")
+		}
+
+		deliveries, err := webhooks.Deliveries(context.Background(), search.ID.Hex(), maxDeliveries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 1 || !deliveries[0].Delivered {
+			t.Errorf("expected a successful delivery, got %+v", deliveries)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package search
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/analytics"
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/geohash"
+)
+
+// EventDemand represents the event where searches expired without finding any
+// trip, so that drivers can be invited to offer one.
+const EventDemand = "UNMET_DEMAND"
+
+const demandChannel = "demand"
+
+const (
+	// demandPrecision represents the precision of the geohash cells of the
+	// corridors, the same as the default one of the unmet demand reported to
+	// admins.
+	demandPrecision = analytics.DefaultDemandPrecision
+
+	// demandWindow represents the length of the time windows in which the
+	// riders wanted to travel.
+	demandWindow = time.Hour
+
+	// demandInterval represents how often the unmet demand is published.
+	demandInterval = time.Minute
+)
+
+// A Demand represents the searches that expired without finding any trip
+// between two cells, in the same time window.
+type Demand struct {
+	// SourceCell represents the geohash cell where the riders wanted to
+	// leave from.
+	SourceCell string `json:"sourceCell"`
+
+	// DestinationCell represents the geohash cell where the riders wanted to
+	// go.
+	DestinationCell string `json:"destinationCell"`
+
+	// Source and Destination represent the average of the points where the
+	// riders wanted to leave from and go, to suggest where a trip could start
+	// and end.
+	Source      *entity.Point `json:"source"`
+	Destination *entity.Point `json:"destination"`
+
+	// WindowStart and WindowEnd represent the time window in which the riders
+	// wanted to travel. WindowStart is included and WindowEnd is not.
+	WindowStart time.Time `json:"windowStart"`
+	WindowEnd   time.Time `json:"windowEnd"`
+
+	// Searches represents how many searches expired without finding a trip.
+	Searches int `json:"searches"`
+
+	// Seats represents how many seats the riders requested in total. A search
+	// that does not specify seats counts for one.
+	Seats int `json:"seats"`
+}
+
+type demandKey struct {
+	source      string
+	destination string
+	window      time.Time
+}
+
+// A demandAggregator groups the searches that expired without finding any
+// trip by corridor and time window until they are published.
+//
+// Each instance aggregates the searches it ran, so the demand of a corridor and
+// time window may be published by several instances, each with a part of it.
+//
+// It is safe for concurrent use.
+type demandAggregator struct {
+	mu     sync.Mutex
+	groups map[demandKey]*Demand
+}
+
+func newDemandAggregator() *demandAggregator {
+	return &demandAggregator{groups: make(map[demandKey]*Demand)}
+}
+
+// add adds a search's filters to the demand of its corridor and time window.
+func (a *demandAggregator) add(f *entity.Filters) {
+	if f == nil || f.Source == nil || f.Destination == nil {
+		return
+	}
+
+	window := f.TravelAt().UTC().Truncate(demandWindow)
+
+	seats := 1
+	if f.Seats != nil && *f.Seats > 0 {
+		seats = *f.Seats
+	}
+
+	source, destination := *f.Source, *f.Destination
+	a.merge(&Demand{
+		SourceCell:      geohash.Encode(f.Source.Latitude, f.Source.Longitude, demandPrecision),
+		DestinationCell: geohash.Encode(f.Destination.Latitude, f.Destination.Longitude, demandPrecision),
+		Source:          &source,
+		Destination:     &destination,
+		WindowStart:     window,
+		WindowEnd:       window.Add(demandWindow),
+		Searches:        1,
+		Seats:           seats,
+	})
+}
+
+// merge adds demand to the demand of its corridor and time window, like the
+// demand that could not be published, so that it is published the next time.
+func (a *demandAggregator) merge(demand *Demand) {
+	if demand.Searches <= 0 {
+		return
+	}
+
+	k := demandKey{demand.SourceCell, demand.DestinationCell, demand.WindowStart}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	d, ok := a.groups[k]
+	if !ok {
+		d = &Demand{
+			SourceCell:      k.source,
+			DestinationCell: k.destination,
+			Source:          &entity.Point{},
+			Destination:     &entity.Point{},
+			WindowStart:     k.window,
+			WindowEnd:       k.window.Add(demandWindow),
+		}
+		a.groups[k] = d
+	}
+
+	// The points are kept as running averages, weighted by the number of
+	// searches, so that they never need to be divided once the demand is
+	// taken.
+	w := float64(demand.Searches) / float64(d.Searches+demand.Searches)
+	d.Source.Latitude += (demand.Source.Latitude - d.Source.Latitude) * w
+	d.Source.Longitude += (demand.Source.Longitude - d.Source.Longitude) * w
+	d.Destination.Latitude += (demand.Destination.Latitude - d.Destination.Latitude) * w
+	d.Destination.Longitude += (demand.Destination.Longitude - d.Destination.Longitude) * w
+
+	d.Searches += demand.Searches
+	d.Seats += demand.Seats
+}
+
+// take returns the demand aggregated since it was last taken, from the most
+// searched, and starts aggregating anew.
+func (a *demandAggregator) take() []*Demand {
+	a.mu.Lock()
+	groups := a.groups
+	a.groups = make(map[demandKey]*Demand)
+	a.mu.Unlock()
+
+	demand := make([]*Demand, 0, len(groups))
+	for _, d := range groups {
+		demand = append(demand, d)
+	}
+	sort.Slice(demand, func(i, j int) bool {
+		a, b := demand[i], demand[j]
+		if a.Searches != b.Searches {
+			return a.Searches > b.Searches
+		}
+
+		if a.SourceCell != b.SourceCell {
+			return a.SourceCell < b.SourceCell
+		}
+
+		if a.DestinationCell != b.DestinationCell {
+			return a.DestinationCell < b.DestinationCell
+		}
+
+		return a.WindowStart.Before(b.WindowStart)
+	})
+
+	return demand
+}
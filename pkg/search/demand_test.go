@@ -0,0 +1,77 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+)
+
+func TestDemandAggregator(t *testing.T) {
+	friday := time.Date(2026, 10, 23, 21, 30, 0, 0, time.UTC)
+	plateau := &entity.Point{Latitude: 45.52, Longitude: -73.58}
+
+	filters := func(source, destination *entity.Point, leaveAt time.Time, seats *int) *entity.Filters {
+		return &entity.Filters{Source: source, Destination: destination, LeaveAt: leaveAt, Seats: seats}
+	}
+
+	t.Run("Should group searches by corridor and time window", func(t *testing.T) {
+		a := newDemandAggregator()
+		three := 3
+
+		a.add(filters(montreal, quebec, friday, &three))
+		a.add(filters(plateau, quebec, friday.Add(20*time.Minute), nil))
+		a.add(filters(montreal, quebec, friday.Add(time.Hour), nil))
+		a.add(filters(quebec, montreal, friday, nil))
+
+		demand := a.take()
+		if len(demand) != 3 {
+			t.Fatalf("expected 3 groups, got %d", len(demand))
+		}
+
+		d := demand[0]
+		if d.SourceCell != "f25d" || d.DestinationCell != "f2m6" {
+			t.Errorf("expected corridor f25d to f2m6, got %s to %s", d.SourceCell, d.DestinationCell)
+		}
+		if d.Searches != 2 || d.Seats != 4 {
+			t.Errorf("expected 2 searches for 4 seats, got %d searches for %d seats", d.Searches, d.Seats)
+		}
+		if !d.WindowStart.Equal(friday.Truncate(time.Hour)) || !d.WindowEnd.Equal(d.WindowStart.Add(time.Hour)) {
+			t.Errorf("expected window to start at %s and last an hour, got %s to %s", friday.Truncate(time.Hour), d.WindowStart, d.WindowEnd)
+		}
+
+		expectedLatitude := (montreal.Latitude + plateau.Latitude) / 2
+		if diff := d.Source.Latitude - expectedLatitude; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("expected source latitude %f, got %f", expectedLatitude, d.Source.Latitude)
+		}
+	})
+
+	t.Run("Should start aggregating anew once taken", func(t *testing.T) {
+		a := newDemandAggregator()
+		a.add(filters(montreal, quebec, friday, nil))
+		a.take()
+
+		if demand := a.take(); len(demand) != 0 {
+			t.Errorf("expected no demand, got %d groups", len(demand))
+		}
+	})
+	t.Run("Should publish the demand that is merged back along with the next one", func(t *testing.T) {
+		a := newDemandAggregator()
+		a.add(filters(montreal, quebec, friday, nil))
+
+		for _, d := range a.take() {
+			a.merge(d)
+		}
+		a.add(filters(plateau, quebec, friday, nil))
+
+		demand := a.take()
+		if len(demand) != 1 || demand[0].Searches != 2 || demand[0].Seats != 2 {
+			t.Fatalf("expected 2 searches for 2 seats in a single group, got %+v", demand)
+		}
+
+		expectedLatitude := (montreal.Latitude + plateau.Latitude) / 2
+		if diff := demand[0].Source.Latitude - expectedLatitude; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("expected source latitude %f, got %f", expectedLatitude, demand[0].Source.Latitude)
+		}
+	})
+}
@@ -1,5 +1,31 @@
 package search
 
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors returned by repositories, possibly wrapped with more details.
+// They are compared with errors.Is.
+var (
+	// ErrNotFound represents that no search has the given ID.
+	ErrNotFound = errors.New("no search found")
+
+	// ErrInvalidID represents that an ID is not in the format used by the
+	// repository, so no search can have it.
+	ErrInvalidID = errors.New("invalid search ID")
+
+	// ErrUnavailable represents that the database could not be reached or
+	// failed to complete an operation.
+	ErrUnavailable = errors.New("search repository unavailable")
+)
+
+// unavailable wraps an error of the database so that it is reported as
+// ErrUnavailable, along with its cause.
+func unavailable(err error) error {
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
+
 // A NotFoundError is an error that represents that no search was found.
 type NotFoundError struct {
 	msg string
@@ -8,3 +34,9 @@ type NotFoundError struct {
 func (e NotFoundError) Error() string {
 	return e.msg
 }
+
+// Is reports whether the target is ErrNotFound, so that a NotFoundError can be
+// compared with it.
+func (e NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
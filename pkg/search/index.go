@@ -0,0 +1,151 @@
+package search
+
+import (
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/geohash"
+	"googlemaps.github.io/maps"
+)
+
+// A spatialIndex indexes searches by the geohash cells around their source
+// and destination, to quickly find the searches a route might match without
+// evaluating all of them.
+//
+// The cells' precision is derived from each search's radius threshold, so
+// that every point within the threshold of its source or destination falls in
+// one of its cells. Since a route only matches a search when one of its points
+// is within the threshold of both, only searches with cells containing a
+// point of the route on both ends are candidates.
+//
+// It is not safe for concurrent use.
+type spatialIndex struct {
+	sources      map[int]map[string]map[string]bool
+	destinations map[int]map[string]map[string]bool
+	searches     map[string]*indexedSearch
+}
+
+type indexedSearch struct {
+	precision        int
+	sourceCells      []string
+	destinationCells []string
+}
+
+func newSpatialIndex() *spatialIndex {
+	return &spatialIndex{
+		sources:      make(map[int]map[string]map[string]bool),
+		destinations: make(map[int]map[string]map[string]bool),
+		searches:     make(map[string]*indexedSearch),
+	}
+}
+
+// add indexes the search's source and destination. A search without a source
+// or destination cannot be indexed.
+func (idx *spatialIndex) add(id string, f *entity.Filters) {
+	if f == nil || f.Source == nil || f.Destination == nil {
+		return
+	}
+
+	idx.remove(id)
+
+	radius := float64(f.Radius())
+
+	// The precision must suit both ends, so the one closest to a pole, where
+	// cells are narrowest, decides.
+	precision := geohash.PrecisionForRadius(f.Source.Latitude, radius)
+	if p := geohash.PrecisionForRadius(f.Destination.Latitude, radius); p < precision {
+		precision = p
+	}
+
+	s := &indexedSearch{
+		precision:        precision,
+		sourceCells:      geohash.Cover(f.Source.Latitude, f.Source.Longitude, precision),
+		destinationCells: geohash.Cover(f.Destination.Latitude, f.Destination.Longitude, precision),
+	}
+	idx.searches[id] = s
+
+	addToCells(idx.sources, precision, s.sourceCells, id)
+	addToCells(idx.destinations, precision, s.destinationCells, id)
+}
+
+// remove removes the search from the index.
+func (idx *spatialIndex) remove(id string) {
+	s, ok := idx.searches[id]
+	if !ok {
+		return
+	}
+
+	removeFromCells(idx.sources, s.precision, s.sourceCells, id)
+	removeFromCells(idx.destinations, s.precision, s.destinationCells, id)
+
+	delete(idx.searches, id)
+}
+
+// candidates returns the IDs of the searches that the route might match.
+func (idx *spatialIndex) candidates(points []maps.LatLng) []string {
+	var ids []string
+
+	for precision, sourceCells := range idx.sources {
+		destinationCells := idx.destinations[precision]
+
+		routeCells := make(map[string]bool)
+		for _, p := range points {
+			routeCells[geohash.Encode(p.Lat, p.Lng, precision)] = true
+		}
+
+		nearSource := make(map[string]bool)
+		for c := range routeCells {
+			for id := range sourceCells[c] {
+				nearSource[id] = true
+			}
+		}
+
+		if len(nearSource) == 0 {
+			continue
+		}
+
+		nearBoth := make(map[string]bool)
+		for c := range routeCells {
+			for id := range destinationCells[c] {
+				if nearSource[id] && !nearBoth[id] {
+					nearBoth[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+
+	return ids
+}
+
+func addToCells(cellsByPrecision map[int]map[string]map[string]bool, precision int, cells []string, id string) {
+	byCell, ok := cellsByPrecision[precision]
+	if !ok {
+		byCell = make(map[string]map[string]bool)
+		cellsByPrecision[precision] = byCell
+	}
+
+	for _, c := range cells {
+		ids, ok := byCell[c]
+		if !ok {
+			ids = make(map[string]bool)
+			byCell[c] = ids
+		}
+
+		ids[id] = true
+	}
+}
+
+func removeFromCells(cellsByPrecision map[int]map[string]map[string]bool, precision int, cells []string, id string) {
+	byCell := cellsByPrecision[precision]
+
+	for _, c := range cells {
+		delete(byCell[c], id)
+
+		if len(byCell[c]) == 0 {
+			delete(byCell, c)
+		}
+	}
+
+	if len(byCell) == 0 {
+		delete(cellsByPrecision, precision)
+	}
+}
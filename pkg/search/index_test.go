@@ -0,0 +1,153 @@
+package search
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"googlemaps.github.io/maps"
+)
+
+func newTestRoute(points ...*entity.Point) []maps.LatLng {
+	var route []maps.LatLng
+	for i := 1; i < len(points); i++ {
+		from, to := points[i-1], points[i]
+
+		// Add intermediate points, like a real route would have.
+		for step := 0; step < 100; step++ {
+			ratio := float64(step) / 100
+			route = append(route, maps.LatLng{
+				Lat: from.Latitude + (to.Latitude-from.Latitude)*ratio,
+				Lng: from.Longitude + (to.Longitude-from.Longitude)*ratio,
+			})
+		}
+	}
+
+	last := points[len(points)-1]
+
+	return append(route, maps.LatLng{Lat: last.Latitude, Lng: last.Longitude})
+}
+
+func newRandomTestSearches(n int, r *rand.Rand) []*entity.Search {
+	randomPoint := func() *entity.Point {
+		// Somewhere between Gatineau and Rimouski.
+		return &entity.Point{
+			Latitude:  45 + r.Float64()*3.5,
+			Longitude: -76 + r.Float64()*7.5,
+		}
+	}
+
+	searches := make([]*entity.Search, n)
+	for i := range searches {
+		radius := 500 + r.Intn(20000)
+		searches[i] = &entity.Search{
+			ID: entity.ID(fmt.Sprintf("%d", i)),
+			Filters: &entity.Filters{
+				Source:       randomPoint(),
+				Destination:  randomPoint(),
+				RadiusThresh: &radius,
+			},
+		}
+	}
+
+	return searches
+}
+
+func TestSpatialIndex(t *testing.T) {
+	route := newTestRoute(montreal, drummondville, quebec)
+
+	t.Run("Should only return searches close to the route on both ends", func(t *testing.T) {
+		idx := newSpatialIndex()
+		idx.add("match", newTestSearch("match", montreal, quebec).Filters)
+		idx.add("reversed", newTestSearch("reversed", quebec, montreal).Filters)
+		idx.add("far", newTestSearch("far", &entity.Point{Latitude: 48.45, Longitude: -68.52}, quebec).Filters)
+
+		candidates := idx.candidates(route)
+
+		found := make(map[string]bool)
+		for _, id := range candidates {
+			found[id] = true
+		}
+
+		if !found["match"] || !found["reversed"] || found["far"] {
+			t.Errorf("unexpected candidates %v", candidates)
+		}
+	})
+
+	t.Run("Should not return removed searches", func(t *testing.T) {
+		idx := newSpatialIndex()
+		idx.add("removed", newTestSearch("removed", montreal, quebec).Filters)
+		idx.remove("removed")
+
+		if candidates := idx.candidates(route); len(candidates) != 0 {
+			t.Errorf("unexpected candidates %v", candidates)
+		}
+
+		if len(idx.sources) != 0 || len(idx.destinations) != 0 {
+			t.Errorf("expected the index to be empty")
+		}
+	})
+
+	t.Run("Should return every search the route matches", func(t *testing.T) {
+		searches := newRandomTestSearches(2000, rand.New(rand.NewSource(1)))
+
+		idx := newSpatialIndex()
+		for _, s := range searches {
+			idx.add(s.ID.Hex(), s.Filters)
+		}
+
+		candidates := make(map[string]bool)
+		for _, id := range idx.candidates(route) {
+			candidates[id] = true
+		}
+
+		matches := 0
+		for _, s := range searches {
+			if validateTrip(nil, s.Filters, route) {
+				matches++
+
+				if !candidates[s.ID.Hex()] {
+					t.Errorf("search \"%s\" matches the route but is not a candidate", s.ID)
+				}
+			}
+		}
+
+		if matches == 0 {
+			t.Errorf("expected some searches to match the route")
+		}
+	})
+}
+
+func benchmarkMatching(b *testing.B, n int, indexed bool) {
+	searches := newRandomTestSearches(n, rand.New(rand.NewSource(1)))
+	route := newTestRoute(montreal, drummondville, quebec)
+
+	idx := newSpatialIndex()
+	byID := make(map[string]*entity.Search, n)
+	for _, s := range searches {
+		idx.add(s.ID.Hex(), s.Filters)
+		byID[s.ID.Hex()] = s
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if indexed {
+			for _, id := range idx.candidates(route) {
+				validateTrip(nil, byID[id].Filters, route)
+			}
+		} else {
+			for _, s := range searches {
+				validateTrip(nil, s.Filters, route)
+			}
+		}
+	}
+}
+
+func BenchmarkMatchingBruteForce100(b *testing.B)   { benchmarkMatching(b, 100, false) }
+func BenchmarkMatchingIndexed100(b *testing.B)      { benchmarkMatching(b, 100, true) }
+func BenchmarkMatchingBruteForce1000(b *testing.B)  { benchmarkMatching(b, 1000, false) }
+func BenchmarkMatchingIndexed1000(b *testing.B)     { benchmarkMatching(b, 1000, true) }
+func BenchmarkMatchingBruteForce10000(b *testing.B) { benchmarkMatching(b, 10000, false) }
+func BenchmarkMatchingIndexed10000(b *testing.B)    { benchmarkMatching(b, 10000, true) }
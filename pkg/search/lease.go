@@ -0,0 +1,148 @@
+package search
+
+import (
+	"context"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+)
+
+// A Lease represents an instance's exclusive right to run a search's worker
+// until it expires.
+//
+// Instances renew the leases of the searches they run periodically. When an
+// instance stops renewing them, for example because it crashed, its leases
+// expire and the searches are taken over by other instances.
+type Lease struct {
+	// Owner represents the unique identifier of the instance that holds the
+	// lease.
+	Owner string
+
+	// ExpiresAt represents when the lease expires if it isn't renewed.
+	ExpiresAt time.Time
+}
+
+// LeaseConfig contains the information required for an instance to take part
+// in sharing searches with other instances.
+type LeaseConfig struct {
+	// InstanceID specifies the unique identifier of the instance. No two
+	// running instances can share it.
+	InstanceID string
+
+	// TTL specifies how long a lease lasts before it expires. Leases are
+	// renewed three times per TTL.
+	//
+	// A TTL of zero means DefaultLeaseTTL.
+	TTL time.Duration
+}
+
+// DefaultLeaseTTL represents the default amount of time a lease lasts before
+// it expires.
+const DefaultLeaseTTL = 30 * time.Second
+
+func (conf *LeaseConfig) newLease() *Lease {
+	return &Lease{Owner: conf.InstanceID, ExpiresAt: time.Now().Add(conf.TTL)}
+}
+
+// maintainLeases periodically renews the leases of the searches run by this
+// instance, expires those whose travel time has passed and takes over the
+// searches whose lease has expired, until the context is done. It also
+// publishes the unmet demand every demandInterval.
+//
+// When the leases could not be renewed for a TTL, the workers are stopped and
+// the leases are released once the repository can be reached again.
+func (s *Service) maintainLeases(ctx context.Context) {
+	ticker := time.NewTicker(s.leases.TTL / 3)
+	defer ticker.Stop()
+
+	lastRenewal := time.Now()
+	lastDemand := time.Now()
+	lost := false
+	for {
+		if lost {
+			// The leases still name this instance, so they would be renewed
+			// without their searches running anywhere. They are released
+			// instead, to be taken over again.
+			err := s.repo.ReleaseLeases(ctx, s.leases.InstanceID)
+			if err == nil {
+				lost = false
+				lastRenewal = time.Now()
+			}
+		} else if s.renewLeases(ctx) {
+			lastRenewal = time.Now()
+		} else if time.Since(lastRenewal) > s.leases.TTL {
+			// The leases have expired, so other instances may already be
+			// running the searches.
+			for _, id := range s.orchestrator.SearchIDs() {
+				s.stopWorker(id)
+			}
+			lost = true
+		}
+
+		s.expireSearches(ctx)
+		s.acquireExpiredLeases(ctx)
+
+		if time.Since(lastDemand) >= demandInterval {
+			s.publishDemand(ctx)
+			lastDemand = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// renewLeases renews the leases held by this instance and stops the workers
+// of the searches whose lease it no longer holds, because they were deleted or
+// taken over. It returns whether the leases could be renewed.
+func (s *Service) renewLeases(ctx context.Context) bool {
+	// The running searches must be listed before renewing, since searches
+	// created in the meantime would not be part of the renewed leases.
+	running := s.orchestrator.SearchIDs()
+
+	IDs, err := s.repo.RenewLeases(ctx, s.leases.newLease())
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to renew leases", "error", err)
+		return false
+	}
+
+	held := make(map[string]bool, len(IDs))
+	for _, id := range IDs {
+		held[id.Hex()] = true
+	}
+
+	for _, id := range running {
+		if !held[id] {
+			s.stopWorker(id)
+		}
+	}
+
+	return true
+}
+
+// acquireExpiredLeases takes over the searches whose lease has expired and
+// starts their worker on this instance.
+func (s *Service) acquireExpiredLeases(ctx context.Context) {
+	for {
+		search, err := s.repo.AcquireExpiredLease(ctx, s.leases.newLease())
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to acquire expired leases", "error", err)
+			return
+		} else if search == nil {
+			return
+		}
+
+		searchCtx := logging.WithSearchID(ctx, search.ID.Hex())
+
+		err = s.startWorker(ctx, search)
+		if err != nil {
+			s.logger.ErrorContext(searchCtx, "failed to take over search", "error", err)
+			continue
+		}
+
+		s.logger.InfoContext(searchCtx, "took over search")
+	}
+}
@@ -0,0 +1,107 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+)
+
+func TestServiceLeases(t *testing.T) {
+	const TTL = 60 * time.Millisecond
+
+	ctx := context.Background()
+
+	// newExpiredSearch creates a search whose lease, held by an instance that
+	// stopped, has expired.
+	newExpiredSearch := func(t *testing.T, repo Repository) entity.ID {
+		search := newTestSearch("", montreal, quebec)
+		search.Filters.LeaveAt = time.Now().Add(time.Hour)
+
+		ID, err := repo.Create(ctx, search, &Lease{Owner: "stopped", ExpiresAt: time.Now().Add(-time.Second)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ID
+	}
+
+	newRepository := func(t *testing.T) *leaseRepository {
+		memory, err := NewMemoryRepository()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return &leaseRepository{Repository: memory, log: &eventLog{}}
+	}
+
+	t.Run("Should take over and restart the searches whose lease expired", func(t *testing.T) {
+		repo := newRepository(t)
+		ID := newExpiredSearch(t, repo)
+
+		s := newTestService(t, repo, &fakePubSub{log: &eventLog{}}, "instance", TTL)
+		defer s.Shutdown(ctx)
+
+		eventually(t, func() bool { return runs(s, ID) }, "expected the search to be taken over")
+	})
+
+	t.Run("Should renew the leases of the searches it runs", func(t *testing.T) {
+		repo := newRepository(t)
+		ID := newExpiredSearch(t, repo)
+
+		s := newTestService(t, repo, &fakePubSub{log: &eventLog{}}, "instance", TTL)
+		defer s.Shutdown(ctx)
+
+		eventually(t, func() bool { return runs(s, ID) }, "expected the search to be taken over")
+
+		time.Sleep(3 * TTL)
+
+		search, err := repo.AcquireExpiredLease(ctx, &Lease{Owner: "other", ExpiresAt: time.Now().Add(TTL)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if search != nil {
+			t.Error("expected the lease to be renewed")
+		}
+	})
+
+	t.Run("Should stop the workers of the searches whose lease was lost", func(t *testing.T) {
+		repo := newRepository(t)
+		ID := newExpiredSearch(t, repo)
+
+		s := newTestService(t, repo, &fakePubSub{log: &eventLog{}}, "instance", TTL)
+		defer s.Shutdown(ctx)
+
+		eventually(t, func() bool { return runs(s, ID) }, "expected the search to be taken over")
+
+		// Another instance deleted the search.
+		_, err := repo.Delete(ctx, ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		eventually(t, func() bool { return !runs(s, ID) }, "expected the worker of the deleted search to be stopped")
+	})
+
+	t.Run("Should stop every worker once its leases could not be renewed for a TTL", func(t *testing.T) {
+		repo := newRepository(t)
+		ID := newExpiredSearch(t, repo)
+
+		s := newTestService(t, repo, &fakePubSub{log: &eventLog{}}, "instance", TTL)
+		defer s.Shutdown(ctx)
+
+		eventually(t, func() bool { return runs(s, ID) }, "expected the search to be taken over")
+
+		repo.fail(errors.New("database is unreachable"))
+
+		eventually(t, func() bool { return !runs(s, ID) }, "expected the worker to be stopped once its lease expired")
+
+		// The search is taken over again once the database is back.
+		repo.fail(nil)
+
+		eventually(t, func() bool { return runs(s, ID) }, "expected the search to be taken over again")
+	})
+}
@@ -0,0 +1,262 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"github.com/mongodb/mongo-go-driver/bson/primitive"
+)
+
+// A MemoryRepository is a repository that keeps searches in memory. It behaves
+// like a MongoRepository, from the format of the IDs it generates to the
+// errors it returns, so that it can replace it in tests and when running the
+// service locally.
+//
+// It is safe for concurrent use, but the searches are lost when the service
+// stops and are not shared with other instances.
+type MemoryRepository struct {
+	mu        sync.Mutex
+	documents map[primitive.ObjectID]*memoryDocument
+}
+
+type memoryDocument struct {
+	filters        *entity.Filters
+	status         string
+	owner          string
+	leaseExpiresAt time.Time
+	createdAt      time.Time
+	results        map[entity.ID]bool
+	callbackURL    string
+	callbackSecret string
+}
+
+// NewMemoryRepository creates an empty in-memory search repository.
+func NewMemoryRepository() (Repository, error) {
+	return &MemoryRepository{documents: make(map[primitive.ObjectID]*memoryDocument)}, nil
+}
+
+// copyFilters returns a copy of the filters, so that the stored searches are
+// not changed through the entities given to or returned by the repository.
+func copyFilters(f *entity.Filters) *entity.Filters {
+	if f == nil {
+		return nil
+	}
+
+	c := *f
+	c.Seats = copyInt(f.Seats)
+	c.RadiusThresh = copyInt(f.RadiusThresh)
+	c.Source = copyPoint(f.Source)
+	c.Destination = copyPoint(f.Destination)
+	if f.Details != nil {
+		d := *f.Details
+		c.Details = &d
+	}
+	if f.Recurrence != nil {
+		r := *f.Recurrence
+		r.Days = append([]string(nil), f.Recurrence.Days...)
+		c.Recurrence = &r
+	}
+
+	return &c
+}
+
+func copyInt(i *int) *int {
+	if i == nil {
+		return nil
+	}
+
+	c := *i
+	return &c
+}
+
+func copyPoint(p *entity.Point) *entity.Point {
+	if p == nil {
+		return nil
+	}
+
+	c := *p
+	return &c
+}
+
+func (d *memoryDocument) entity(id primitive.ObjectID) *entity.Search {
+	return &entity.Search{
+		ID:             entity.NewIDFromHex(id.Hex()),
+		Filters:        copyFilters(d.filters),
+		CallbackURL:    d.callbackURL,
+		CallbackSecret: d.callbackSecret,
+	}
+}
+
+// FindByID retrieves the search with the given ID, if it exists.
+func (r *MemoryRepository) FindByID(ctx context.Context, ID entity.ID) (*entity.Search, error) {
+	objectID, err := primitive.ObjectIDFromHex(string(ID))
+	if err != nil {
+		return nil, fmt.Errorf("search.MemoryRepository: %w \"%s\"", ErrInvalidID, ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.documents[objectID]
+	if !ok {
+		return nil, fmt.Errorf("search.MemoryRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	}
+
+	return d.entity(objectID), nil
+}
+
+// Create stores the new search, along with the lease of the instance that
+// runs it, and returns the unique identifier that was generated for it.
+func (r *MemoryRepository) Create(ctx context.Context, t *entity.Search, lease *Lease) (entity.ID, error) {
+	if t == nil {
+		return entity.NilID, fmt.Errorf("search.MemoryRepository: failed to create search (search is nil)")
+	}
+
+	id := primitive.NewObjectID()
+	if !t.ID.IsZero() {
+		objectID, err := primitive.ObjectIDFromHex(t.ID.Hex())
+		if err != nil {
+			return entity.NilID, fmt.Errorf("search.MemoryRepository: %w \"%s\"", ErrInvalidID, t.ID)
+		}
+
+		id = objectID
+	}
+
+	d := &memoryDocument{
+		filters:        copyFilters(t.Filters),
+		status:         statusActive,
+		createdAt:      time.Now().UTC(),
+		results:        make(map[entity.ID]bool),
+		callbackURL:    t.CallbackURL,
+		callbackSecret: t.CallbackSecret,
+	}
+
+	if lease != nil {
+		d.owner = lease.Owner
+		d.leaseExpiresAt = lease.ExpiresAt
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.documents[id]; ok {
+		return entity.NilID, fmt.Errorf("search.MemoryRepository: failed to create search (duplicate ID \"%s\")", id.Hex())
+	}
+
+	r.documents[id] = d
+
+	return entity.ID(id.Hex()), nil
+}
+
+// Delete removes the search with the given ID, if it exists, and returns its
+// summary.
+func (r *MemoryRepository) Delete(ctx context.Context, ID entity.ID) (*Summary, error) {
+	objectID, err := primitive.ObjectIDFromHex(string(ID))
+	if err != nil {
+		return nil, fmt.Errorf("search.MemoryRepository: %w \"%s\"", ErrInvalidID, ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.documents[objectID]
+	if !ok {
+		return nil, fmt.Errorf("search.MemoryRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	}
+
+	delete(r.documents, objectID)
+
+	return &Summary{
+		Search:    d.entity(objectID),
+		CreatedAt: d.createdAt,
+		Results:   len(d.results),
+	}, nil
+}
+
+// AddResult records that the trip was found for the search with the given ID.
+func (r *MemoryRepository) AddResult(ctx context.Context, ID entity.ID, tripID entity.ID) error {
+	objectID, err := primitive.ObjectIDFromHex(string(ID))
+	if err != nil {
+		return fmt.Errorf("search.MemoryRepository: %w \"%s\"", ErrInvalidID, ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.documents[objectID]
+	if !ok {
+		return fmt.Errorf("search.MemoryRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	}
+
+	if len(d.results) < MaxResults {
+		d.results[tripID] = true
+	}
+
+	return nil
+}
+
+// RenewLeases extends the leases held by the lease's owner until the lease's
+// expiry, and returns the IDs of the searches it holds.
+func (r *MemoryRepository) RenewLeases(ctx context.Context, lease *Lease) ([]entity.ID, error) {
+	if lease == nil {
+		return nil, fmt.Errorf("search.MemoryRepository: failed to renew leases (lease is nil)")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var IDs []entity.ID
+	for id, d := range r.documents {
+		if d.owner == lease.Owner && d.status == statusActive {
+			d.leaseExpiresAt = lease.ExpiresAt
+			IDs = append(IDs, entity.ID(id.Hex()))
+		}
+	}
+
+	return IDs, nil
+}
+
+// AcquireExpiredLease gives the lease of a search whose lease has expired to
+// the lease's owner, and returns the search. It returns nil when no lease has
+// expired.
+func (r *MemoryRepository) AcquireExpiredLease(ctx context.Context, lease *Lease) (*entity.Search, error) {
+	if lease == nil {
+		return nil, fmt.Errorf("search.MemoryRepository: failed to acquire lease (lease is nil)")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, d := range r.documents {
+		if (d.status == statusActive || d.status == statusResumable) && d.leaseExpiresAt.Before(now) {
+			d.status = statusActive
+			d.owner = lease.Owner
+			d.leaseExpiresAt = lease.ExpiresAt
+
+			return d.entity(id), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ReleaseLeases gives up the leases held by the owner and marks their searches
+// resumable, so that other instances take them over right away.
+func (r *MemoryRepository) ReleaseLeases(ctx context.Context, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, d := range r.documents {
+		if d.owner == owner && d.status == statusActive {
+			d.status = statusResumable
+			d.owner = ""
+			d.leaseExpiresAt = time.Time{}
+		}
+	}
+
+	return nil
+}
@@ -3,11 +3,16 @@ package search
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
 	"github.com/mongodb/mongo-go-driver/bson"
 	"github.com/mongodb/mongo-go-driver/bson/primitive"
 	"github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/mongo/options"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // A MongoRepository is a repository that performs CRUD operations on searches
@@ -17,10 +22,116 @@ type MongoRepository struct {
 }
 
 type document struct {
-	ID primitive.ObjectID `bson:"_id,omitempty"`
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	Filters        *filtersDocument   `bson:"filters"`
+	Status         string             `bson:"status"`
+	Owner          string             `bson:"owner"`
+	LeaseExpiresAt time.Time          `bson:"leaseExpiresAt"`
+	CreatedAt      time.Time          `bson:"createdAt"`
+	ExpiresAt      time.Time          `bson:"expiresAt,omitempty"`
+	Results        []string           `bson:"results,omitempty"`
+	CallbackURL    string             `bson:"callbackUrl,omitempty"`
+	CallbackSecret string             `bson:"callbackSecret,omitempty"`
 }
 
-func newDocumentFromEntity(t *entity.Search) (*document, error) {
+type filtersDocument struct {
+	Seats        *int                `bson:"seats,omitempty"`
+	LeaveAt      time.Time           `bson:"leaveAt,omitempty"`
+	ArriveBy     time.Time           `bson:"arriveBy,omitempty"`
+	LeaveAfter   time.Time           `bson:"leaveAfter,omitempty"`
+	LeaveBefore  time.Time           `bson:"leaveBefore,omitempty"`
+	ArriveAfter  time.Time           `bson:"arriveAfter,omitempty"`
+	ArriveBefore time.Time           `bson:"arriveBefore,omitempty"`
+	Details      *entity.Details     `bson:"details,omitempty"`
+	RadiusThresh *int                `bson:"radiusThresh,omitempty"`
+	Source       *entity.Point       `bson:"source"`
+	Destination  *entity.Point       `bson:"destination"`
+	Recurrence   *recurrenceDocument `bson:"recurrence,omitempty"`
+}
+
+type recurrenceDocument struct {
+	Days      []string `bson:"days"`
+	TimeOfDay string   `bson:"timeOfDay"`
+	TimeZone  string   `bson:"timeZone,omitempty"`
+	StartDate string   `bson:"startDate"`
+	EndDate   string   `bson:"endDate"`
+}
+
+func newFiltersDocument(f *entity.Filters) *filtersDocument {
+	if f == nil {
+		return nil
+	}
+
+	return &filtersDocument{
+		Seats:        f.Seats,
+		LeaveAt:      f.LeaveAt,
+		ArriveBy:     f.ArriveBy,
+		LeaveAfter:   f.LeaveAfter,
+		LeaveBefore:  f.LeaveBefore,
+		ArriveAfter:  f.ArriveAfter,
+		ArriveBefore: f.ArriveBefore,
+		Details:      f.Details,
+		RadiusThresh: f.RadiusThresh,
+		Source:       f.Source,
+		Destination:  f.Destination,
+		Recurrence:   newRecurrenceDocument(f.Recurrence),
+	}
+}
+
+func (d *filtersDocument) Entity() *entity.Filters {
+	if d == nil {
+		return nil
+	}
+
+	return &entity.Filters{
+		Seats:        d.Seats,
+		LeaveAt:      d.LeaveAt,
+		ArriveBy:     d.ArriveBy,
+		LeaveAfter:   d.LeaveAfter,
+		LeaveBefore:  d.LeaveBefore,
+		ArriveAfter:  d.ArriveAfter,
+		ArriveBefore: d.ArriveBefore,
+		Details:      d.Details,
+		RadiusThresh: d.RadiusThresh,
+		Source:       d.Source,
+		Destination:  d.Destination,
+		Recurrence:   d.Recurrence.Entity(),
+	}
+}
+
+func newRecurrenceDocument(r *entity.Recurrence) *recurrenceDocument {
+	if r == nil {
+		return nil
+	}
+
+	return &recurrenceDocument{
+		Days:      r.Days,
+		TimeOfDay: r.TimeOfDay,
+		TimeZone:  r.TimeZone,
+		StartDate: r.StartDate,
+		EndDate:   r.EndDate,
+	}
+}
+
+func (d *recurrenceDocument) Entity() *entity.Recurrence {
+	if d == nil {
+		return nil
+	}
+
+	return entity.NewRecurrence(d.Days, d.TimeOfDay, d.TimeZone, d.StartDate, d.EndDate)
+}
+
+const (
+	// statusActive represents a search that is running on an instance, or
+	// waiting to be taken over by one because its lease expired.
+	statusActive = "active"
+
+	// statusResumable represents a search that was running on an instance
+	// that shut down, waiting to be taken over by another one.
+	statusResumable = "resumable"
+)
+
+func newDocumentFromEntity(t *entity.Search, lease *Lease) (*document, error) {
 	if t == nil {
 		return nil, fmt.Errorf("trop.MongoRepository: entity is nil")
 	}
@@ -31,20 +142,68 @@ func newDocumentFromEntity(t *entity.Search) (*document, error) {
 	} else {
 		objectID, err := primitive.ObjectIDFromHex(t.ID.Hex())
 		if err != nil {
-			return nil, fmt.Errorf("search.MongoRepository: failed to create object")
+			return nil, fmt.Errorf("search.MongoRepository: %w \"%s\"", ErrInvalidID, t.ID)
 		}
 
 		id = objectID
 	}
 
-	return &document{
-		id,
-	}, nil
+	d := &document{
+		ID:             id,
+		Filters:        newFiltersDocument(t.Filters),
+		Status:         statusActive,
+		CreatedAt:      time.Now().UTC(),
+		CallbackURL:    t.CallbackURL,
+		CallbackSecret: t.CallbackSecret,
+	}
+
+	if t.Filters != nil {
+		d.ExpiresAt = t.Filters.TravelAt().UTC()
+	}
+
+	if lease != nil {
+		d.Owner = lease.Owner
+		d.LeaseExpiresAt = lease.ExpiresAt
+	}
+
+	return d, nil
 }
 
 func (d document) Entity() *entity.Search {
 	return &entity.Search{
-		ID: entity.NewIDFromHex(d.ID.Hex()),
+		ID:             entity.NewIDFromHex(d.ID.Hex()),
+		Filters:        d.Filters.Entity(),
+		CallbackURL:    d.CallbackURL,
+		CallbackSecret: d.CallbackSecret,
+	}
+}
+
+func (d document) Summary() *Summary {
+	return &Summary{
+		Search:    d.Entity(),
+		CreatedAt: d.CreatedAt,
+		Results:   len(d.Results),
+	}
+}
+
+// operationTimeout represents how long to wait for the database server to
+// complete an operation before giving up on it.
+const operationTimeout = 5 * time.Second
+
+// startOperation gives the database server operationTimeout to complete an
+// operation, and traces it. The returned function must be called with the
+// operation's error once it is done.
+func (r *MongoRepository) startOperation(ctx context.Context, operation string) (context.Context, func(error)) {
+	ctx, span := tracer.Start(ctx, "mongo."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemMongoDB,
+		semconv.DBCollectionName(r.collection.Name()),
+		semconv.DBOperationName(operation),
+	))
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+
+	return ctx, func(err error) {
+		cancel()
+		tracing.End(span, err)
 	}
 }
 
@@ -58,36 +217,45 @@ func NewMongoRepository(collection *mongo.Collection) (Repository, error) {
 }
 
 // FindByID retrieves the search with the given ID, if it exists.
-func (r *MongoRepository) FindByID(ID entity.ID) (*entity.Search, error) {
+func (r *MongoRepository) FindByID(ctx context.Context, ID entity.ID) (_ *entity.Search, err error) {
 	objectID, err := primitive.ObjectIDFromHex(string(ID))
 	if err != nil {
-		return nil, fmt.Errorf("search.MongoRepository: failed to create object ID")
+		return nil, fmt.Errorf("search.MongoRepository: %w \"%s\"", ErrInvalidID, ID)
 	}
 
-	filter := bson.D{{"_id", objectID}}
+	ctx, done := r.startOperation(ctx, "findOne")
+	defer func() { done(err) }()
+
+	filter := bson.D{{Key: "_id", Value: objectID}}
 	var d document
-	err = r.collection.FindOne(context.TODO(), filter).Decode(&d)
-	if err != nil {
-		return nil, fmt.Errorf("search.MongoRepository: no search found with ID \"%s\" (%s)", ID, err)
+	err = r.collection.FindOne(ctx, filter).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("search.MongoRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	} else if err != nil {
+		return nil, fmt.Errorf("search.MongoRepository: failed to find search with ID \"%s\" (%w)", ID, unavailable(err))
 	}
 	return d.Entity(), nil
 }
 
-// Create stores the new search in the database and returns the unique
-// identifier that was generated for it.
-func (r *MongoRepository) Create(t *entity.Search) (entity.ID, error) {
+// Create stores the new search in the database, along with the lease of the
+// instance that runs it, and returns the unique identifier that was generated
+// for it.
+func (r *MongoRepository) Create(ctx context.Context, t *entity.Search, lease *Lease) (_ entity.ID, err error) {
 	if t == nil {
 		return entity.NilID, fmt.Errorf("search.MongoRepository: failed to create search (search is nil)")
 	}
 
-	d, err := newDocumentFromEntity(t)
+	d, err := newDocumentFromEntity(t, lease)
 	if err != nil {
-		return entity.NilID, fmt.Errorf("search.MongoRepository: failed to create search document from entity (%s)", err)
+		return entity.NilID, fmt.Errorf("search.MongoRepository: failed to create search document from entity (%w)", err)
 	}
 
-	res, err := r.collection.InsertOne(context.TODO(), d)
+	ctx, done := r.startOperation(ctx, "insertOne")
+	defer func() { done(err) }()
+
+	res, err := r.collection.InsertOne(ctx, d)
 	if err != nil {
-		return entity.NilID, fmt.Errorf("search.MongoRepository: failed to create search (%s)", err)
+		return entity.NilID, fmt.Errorf("search.MongoRepository: failed to create search (%w)", unavailable(err))
 	}
 
 	ID, ok := res.InsertedID.(primitive.ObjectID)
@@ -98,17 +266,159 @@ func (r *MongoRepository) Create(t *entity.Search) (entity.ID, error) {
 	return entity.ID(ID.Hex()), nil
 }
 
-// Delete removes the search with the given ID from the database.
-func (r *MongoRepository) Delete(ID entity.ID) error {
+// Delete removes the search with the given ID from the database, if it exists,
+// and returns its summary.
+func (r *MongoRepository) Delete(ctx context.Context, ID entity.ID) (_ *Summary, err error) {
+	objectID, err := primitive.ObjectIDFromHex(string(ID))
+	if err != nil {
+		return nil, fmt.Errorf("search.MongoRepository: %w \"%s\"", ErrInvalidID, ID)
+	}
+
+	ctx, done := r.startOperation(ctx, "findOneAndDelete")
+	defer func() { done(err) }()
+
+	filter := bson.D{{Key: "_id", Value: objectID}}
+	var d document
+	err = r.collection.FindOneAndDelete(ctx, filter).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("search.MongoRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	} else if err != nil {
+		return nil, fmt.Errorf("search.MongoRepository: failed to delete search with ID \"%s\" (%w)", ID, unavailable(err))
+	}
+
+	return d.Summary(), nil
+}
+
+// AddResult records that the trip was found for the search with the given ID.
+func (r *MongoRepository) AddResult(ctx context.Context, ID entity.ID, tripID entity.ID) (err error) {
 	objectID, err := primitive.ObjectIDFromHex(string(ID))
 	if err != nil {
-		return fmt.Errorf("search.MongoRepository: failed to create object ID")
+		return fmt.Errorf("search.MongoRepository: %w \"%s\"", ErrInvalidID, ID)
 	}
 
-	filter := bson.D{{"_id", objectID}}
-	_, err = r.collection.DeleteOne(context.TODO(), filter)
+	ctx, done := r.startOperation(ctx, "addResult")
+	defer func() { done(err) }()
+
+	// The search is only updated while it has less than MaxResults results.
+	filter := bson.D{
+		{Key: "_id", Value: objectID},
+		{Key: fmt.Sprintf("results.%d", MaxResults-1), Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	update := bson.D{{Key: "$addToSet", Value: bson.D{{Key: "results", Value: tripID.Hex()}}}}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("search.MongoRepository: failed to add result to search with ID \"%s\" (%w)", ID, unavailable(err))
+	}
+
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	// The search either does not exist or already has all of its results.
+	n, err := r.collection.CountDocuments(ctx, bson.D{{Key: "_id", Value: objectID}})
+	if err != nil {
+		return fmt.Errorf("search.MongoRepository: failed to add result to search with ID \"%s\" (%w)", ID, unavailable(err))
+	} else if n == 0 {
+		return fmt.Errorf("search.MongoRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	}
+
+	return nil
+}
+
+// RenewLeases extends the leases held by the lease's owner until the lease's
+// expiry, and returns the IDs of the searches it holds.
+func (r *MongoRepository) RenewLeases(ctx context.Context, lease *Lease) (_ []entity.ID, err error) {
+	if lease == nil {
+		return nil, fmt.Errorf("search.MongoRepository: failed to renew leases (lease is nil)")
+	}
+
+	ctx, done := r.startOperation(ctx, "renewLeases")
+	defer func() { done(err) }()
+
+	filter := bson.D{
+		{Key: "owner", Value: lease.Owner},
+		{Key: "status", Value: statusActive},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "leaseExpiresAt", Value: lease.ExpiresAt}}}}
+	_, err = r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, fmt.Errorf("search.MongoRepository: failed to renew leases of \"%s\" (%w)", lease.Owner, unavailable(err))
+	}
+
+	cur, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("search.MongoRepository: failed to find leases of \"%s\" (%w)", lease.Owner, unavailable(err))
+	}
+	defer cur.Close(ctx)
+
+	var IDs []entity.ID
+	for cur.Next(ctx) {
+		var d document
+		err := cur.Decode(&d)
+		if err != nil {
+			return nil, fmt.Errorf("search.MongoRepository: failed to decode lease (%s)", err)
+		}
+
+		IDs = append(IDs, entity.ID(d.ID.Hex()))
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("search.MongoRepository: failed to find leases of \"%s\" (%w)", lease.Owner, unavailable(err))
+	}
+
+	return IDs, nil
+}
+
+// AcquireExpiredLease gives the lease of a search whose lease has expired to
+// the lease's owner, and returns the search. It returns nil when no lease has
+// expired.
+func (r *MongoRepository) AcquireExpiredLease(ctx context.Context, lease *Lease) (_ *entity.Search, err error) {
+	if lease == nil {
+		return nil, fmt.Errorf("search.MongoRepository: failed to acquire lease (lease is nil)")
+	}
+
+	ctx, done := r.startOperation(ctx, "findOneAndUpdate")
+	defer func() { done(err) }()
+
+	filter := bson.D{
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{statusActive, statusResumable}}}},
+		{Key: "leaseExpiresAt", Value: bson.D{{Key: "$lt", Value: time.Now()}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: statusActive},
+		{Key: "owner", Value: lease.Owner},
+		{Key: "leaseExpiresAt", Value: lease.ExpiresAt},
+	}}}
+
+	var d document
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("search.MongoRepository: failed to acquire lease (%w)", unavailable(err))
+	}
+
+	return d.Entity(), nil
+}
+
+// ReleaseLeases gives up the leases held by the owner and marks their searches
+// resumable, so that other instances take them over right away.
+func (r *MongoRepository) ReleaseLeases(ctx context.Context, owner string) (err error) {
+	ctx, done := r.startOperation(ctx, "releaseLeases")
+	defer func() { done(err) }()
+
+	filter := bson.D{
+		{Key: "owner", Value: owner},
+		{Key: "status", Value: statusActive},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: statusResumable},
+		{Key: "owner", Value: ""},
+		{Key: "leaseExpiresAt", Value: time.Time{}},
+	}}}
+	_, err = r.collection.UpdateMany(ctx, filter, update)
 	if err != nil {
-		return fmt.Errorf("search.MongoRepository: failed to delete search with ID \"%s\" (%s)", ID, err)
+		return fmt.Errorf("search.MongoRepository: failed to release leases of \"%s\" (%w)", owner, unavailable(err))
 	}
 
 	return nil
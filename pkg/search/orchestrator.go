@@ -1,50 +1,89 @@
 package search
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"sync"
+	"time"
 
 	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/metrics"
 	"azure.com/ecovo/trip-search-service/pkg/pubsub/subscription"
 	"azure.com/ecovo/trip-search-service/pkg/route"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
+	"azure.com/ecovo/trip-search-service/pkg/trip"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"googlemaps.github.io/maps"
 )
 
+var tracer = otel.Tracer("azure.com/ecovo/trip-search-service/pkg/search")
+
 // An Orchestrator manages workers that run asynchronously to gather search
 // results and publish them on subscriptions. It creates, starts, stops and
 // deletes them.
+//
+// It keeps the searches in a spatial index, so that a trip is only sent to the
+// workers of the searches whose source and destination are close to its route.
+// The trips are evaluated by a pool shared by all the workers.
 type Orchestrator struct {
+	mu           sync.RWMutex
 	workers      map[string]*Worker
+	index        *spatialIndex
+	pool         *Pool
 	routeService route.UseCase
+	tripService  trip.UseCase
+	logger       *slog.Logger
 }
 
 // NewOrchestrator creates a search orchestrator to manage workers that run to
-// search for results asynchronously.
-func NewOrchestrator(routeService route.UseCase) *Orchestrator {
-	return &Orchestrator{make(map[string]*Worker), routeService}
+// search for results asynchronously, using the given pool to evaluate trips.
+func NewOrchestrator(routeService route.UseCase, tripService trip.UseCase, pool *Pool, logger *slog.Logger) *Orchestrator {
+	return &Orchestrator{
+		workers:      make(map[string]*Worker),
+		index:        newSpatialIndex(),
+		pool:         pool,
+		routeService: routeService,
+		tripService:  tripService,
+		logger:       logger,
+	}
 }
 
 // StartSearch creates and starts a worker to search for results and publish
 // them to the given subscription. Only one worker can exist for a given search
 // ID.
-func (o *Orchestrator) StartSearch(search *entity.Search, sub subscription.Subscription) error {
+//
+// The worker runs until it is stopped or the given context is done. It starts
+// by retrieving the trips that already exist in the background, so it must
+// not be a request's context.
+func (o *Orchestrator) StartSearch(ctx context.Context, search *entity.Search, sub subscription.Subscription) error {
 	if search == nil {
 		return fmt.Errorf("search.Orchestrator: cannot start worker for nil search")
 	}
 
 	searchID := search.ID.Hex()
 
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
 	_, ok := o.workers[searchID]
 	if ok {
 		return fmt.Errorf("search.Orchestrator: cannot start another worker for same search ID \"%s\"", searchID)
 	}
 
-	worker, err := NewWorker(search.Filters, sub, o.routeService)
+	worker, err := NewWorker(search.Filters, sub, o.backfill(search), o.logger)
 	if err != nil {
 		return err
 	}
 
 	o.workers[searchID] = worker
+	o.index.add(searchID, search.Filters)
+	metrics.ActiveSearches.Inc()
 
-	worker.Start()
+	worker.Start(logging.WithSearchID(ctx, searchID))
 
 	return nil
 }
@@ -55,16 +94,162 @@ func (o *Orchestrator) StopSearch(id string) {
 		return
 	}
 
-	if worker, ok := o.workers[id]; ok {
-		worker.Stop()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	worker, ok := o.workers[id]
+	if !ok {
+		return
 	}
 
+	worker.Stop()
+
 	delete(o.workers, id)
+	o.index.remove(id)
+	metrics.ActiveSearches.Dec()
+}
+
+// Shutdown waits for the trips that were already submitted to be evaluated,
+// until the context is done, and then stops every worker.
+func (o *Orchestrator) Shutdown(ctx context.Context) error {
+	err := o.pool.Stop(ctx)
+
+	for _, id := range o.SearchIDs() {
+		o.StopSearch(id)
+	}
+
+	return err
+}
+
+// SearchIDs returns the IDs of the searches that have a worker.
+func (o *Orchestrator) SearchIDs() []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	IDs := make([]string, 0, len(o.workers))
+	for id := range o.workers {
+		IDs = append(IDs, id)
+	}
+
+	return IDs
+}
+
+// ExpiredSearchIDs returns the IDs of the searches that have a worker and
+// whose travel time has passed at the given time.
+func (o *Orchestrator) ExpiredSearchIDs(now time.Time) []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	var IDs []string
+	for id, worker := range o.workers {
+		if !worker.filters.TravelAt().After(now) {
+			IDs = append(IDs, id)
+		}
+	}
+
+	return IDs
+}
+
+// PublishTrip sends a trip to the workers of the searches whose source and
+// destination are close to its route, so it can be published on Ably.
+//
+// It blocks while the pool's queue is full.
+func (o *Orchestrator) PublishTrip(ctx context.Context, trip *entity.Trip) {
+	points, err := o.routePoints(ctx, trip)
+	if err != nil {
+		o.logger.ErrorContext(ctx, "failed to route trip", "error", err)
+		return
+	}
+
+	o.publishRoutedTrip(ctx, trip, points)
+}
+
+// publishRoutedTrip sends a trip whose route was already retrieved to the
+// workers of the searches whose source and destination are close to it.
+func (o *Orchestrator) publishRoutedTrip(ctx context.Context, trip *entity.Trip, points []maps.LatLng) {
+	o.mu.RLock()
+	var workers []*Worker
+	for _, id := range o.index.candidates(points) {
+		if w, ok := o.workers[id]; ok {
+			workers = append(workers, w)
+		}
+	}
+	o.mu.RUnlock()
+
+	for _, w := range workers {
+		err := o.pool.Submit(ctx, w, routedTrip{trip, points})
+		if err != nil {
+			o.logger.ErrorContext(ctx, "failed to submit trip", "error", err)
+			return
+		}
+	}
+}
+
+// DeliverTrip sends a trip to the worker of a single search, so that no other
+// search evaluates it. Nothing happens if there is no worker for the search.
+func (o *Orchestrator) DeliverTrip(ctx context.Context, id string, trip *entity.Trip) {
+	points, err := o.routePoints(ctx, trip)
+	if err != nil {
+		o.logger.ErrorContext(ctx, "failed to route trip", "error", err)
+		return
+	}
+
+	o.mu.RLock()
+	w, ok := o.workers[id]
+	o.mu.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	err = o.pool.Submit(ctx, w, routedTrip{trip, points})
+	if err != nil {
+		o.logger.ErrorContext(ctx, "failed to submit trip", "error", err)
+	}
+}
+
+// routePoints retrieves the route followed by the trip and returns its
+// points. The route is only retrieved once for all the searches.
+func (o *Orchestrator) routePoints(ctx context.Context, trip *entity.Trip) ([]maps.LatLng, error) {
+	if trip == nil {
+		return nil, fmt.Errorf("search.Orchestrator: cannot route nil trip")
+	}
+
+	r, err := o.routeService.GetRoute(ctx, trip)
+	if err != nil {
+		return nil, fmt.Errorf("search.Orchestrator: failed to get route for trip \"%s\" (%s)", trip.ID, err)
+	}
+
+	points, err := r.OverviewPolyline.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("search.Orchestrator: failed to decode route for trip \"%s\" (%s)", trip.ID, err)
+	}
+
+	return points, nil
 }
 
-// PublishTrip will send trips to the work so it can be published on Ably
-func (o *Orchestrator) PublishTrip(trip *entity.Trip) {
-	for _, w := range o.workers {
-		w.trips <- trip
+// backfill returns a function that retrieves the trips that already exist for
+// the search, one page at a time, and delivers them to the search's worker
+// only.
+func (o *Orchestrator) backfill(search *entity.Search) func(ctx context.Context) error {
+	if o.tripService == nil {
+		return nil
+	}
+
+	searchID := search.ID.Hex()
+
+	return func(ctx context.Context) (err error) {
+		ctx, span := tracer.Start(ctx, "search.Orchestrator.backfill", trace.WithAttributes(
+			attribute.String("search.id", searchID),
+		))
+		defer func() { tracing.End(span, err) }()
+
+		return o.tripService.Find(ctx, search.Filters, func(trips []*entity.Trip) error {
+			for _, t := range trips {
+				o.DeliverTrip(logging.WithTripID(ctx, t.ID.Hex()), searchID, t)
+			}
+
+			return ctx.Err()
+		})
 	}
 }
@@ -0,0 +1,212 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/pubsub/subscription"
+	"azure.com/ecovo/trip-search-service/pkg/trip"
+	"googlemaps.github.io/maps"
+)
+
+type fakeSubscription struct {
+	topic string
+
+	mu       sync.Mutex
+	messages []*subscription.Message
+	received chan struct{}
+}
+
+func newFakeSubscription(topic string) *fakeSubscription {
+	return &fakeSubscription{topic: topic, received: make(chan struct{}, 100)}
+}
+
+func (s *fakeSubscription) Publish(ctx context.Context, msg *subscription.Message) error {
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	s.received <- struct{}{}
+
+	return nil
+}
+
+func (s *fakeSubscription) Subscribe(callback subscription.Callback) error {
+	return nil
+}
+
+func (s *fakeSubscription) Topic() string {
+	return s.topic
+}
+
+func (s *fakeSubscription) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.messages)
+}
+
+// straightRouteService returns routes that go straight from one stop to the
+// next.
+type straightRouteService struct{}
+
+func (straightRouteService) GetRoute(ctx context.Context, t *entity.Trip) (maps.Route, error) {
+	var path []maps.LatLng
+	for _, s := range t.Stops {
+		path = append(path, maps.LatLng{Lat: s.Point.Latitude, Lng: s.Point.Longitude})
+	}
+
+	return maps.Route{OverviewPolyline: maps.Polyline{Points: maps.Encode(path)}}, nil
+}
+
+// backfillTripService returns the given trips, but only for the given filters.
+type backfillTripService struct {
+	filters *entity.Filters
+	trips   []*entity.Trip
+}
+
+func (s *backfillTripService) Find(ctx context.Context, filters *entity.Filters, handle trip.PageHandler) error {
+	if filters != s.filters {
+		return nil
+	}
+
+	return handle(s.trips)
+}
+
+var (
+	montreal      = &entity.Point{Latitude: 45.4944494, Longitude: -73.561703}
+	drummondville = &entity.Point{Latitude: 45.881168, Longitude: -72.484734}
+	quebec        = &entity.Point{Latitude: 46.813877, Longitude: -71.207977}
+)
+
+func newTestTrip(id string, points ...*entity.Point) *entity.Trip {
+	t := &entity.Trip{ID: entity.ID(id), LeaveAt: time.Now().Add(time.Hour)}
+	for _, p := range points {
+		t.Stops = append(t.Stops, &entity.Stop{Point: p})
+	}
+
+	return t
+}
+
+func newTestSearch(id string, source *entity.Point, destination *entity.Point) *entity.Search {
+	radius := 1000
+
+	return &entity.Search{
+		ID: entity.ID(id),
+		Filters: &entity.Filters{
+			Source:       source,
+			Destination:  destination,
+			LeaveAt:      time.Now(),
+			RadiusThresh: &radius,
+		},
+	}
+}
+
+func newTestPool(t *testing.T) *Pool {
+	pool, err := NewPool(2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pool
+}
+
+func waitForMessages(t *testing.T, sub *fakeSubscription, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-sub.received:
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d messages on \"%s\", got %d", n, sub.Topic(), sub.count())
+		}
+	}
+}
+
+func TestOrchestratorBackfill(t *testing.T) {
+	t.Run("Should deliver backfilled trips only to the search that requested them", func(t *testing.T) {
+		other := newTestSearch("other", montreal, quebec)
+		search := newTestSearch("search", montreal, quebec)
+
+		tripService := &backfillTripService{
+			filters: search.Filters,
+			trips: []*entity.Trip{
+				newTestTrip("1", montreal, drummondville, quebec),
+				newTestTrip("2", montreal, quebec),
+			},
+		}
+		o := NewOrchestrator(straightRouteService{}, tripService, newTestPool(t), logging.Discard())
+
+		otherSub := newFakeSubscription("search:other")
+		err := o.StartSearch(context.Background(), other, otherSub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer o.StopSearch("other")
+
+		sub := newFakeSubscription("search:search")
+		err = o.StartSearch(context.Background(), search, sub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer o.StopSearch("search")
+
+		waitForMessages(t, sub, 2)
+
+		// Give the other worker a chance to publish anything it may have
+		// wrongly received.
+		time.Sleep(50 * time.Millisecond)
+
+		if otherSub.count() != 0 {
+			t.Errorf("expected no messages on \"%s\", got %d", otherSub.Topic(), otherSub.count())
+		}
+	})
+
+	t.Run("Should still publish live trips to every search", func(t *testing.T) {
+		o := NewOrchestrator(straightRouteService{}, nil, newTestPool(t), logging.Discard())
+
+		first := newFakeSubscription("search:first")
+		err := o.StartSearch(context.Background(), newTestSearch("first", montreal, quebec), first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer o.StopSearch("first")
+
+		second := newFakeSubscription("search:second")
+		err = o.StartSearch(context.Background(), newTestSearch("second", drummondville, quebec), second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer o.StopSearch("second")
+
+		o.PublishTrip(context.Background(), newTestTrip("1", montreal, drummondville, quebec))
+
+		waitForMessages(t, first, 1)
+		waitForMessages(t, second, 1)
+	})
+}
+
+func TestOrchestratorExpiredSearchIDs(t *testing.T) {
+	t.Run("Should only return the searches whose travel time has passed", func(t *testing.T) {
+		o := NewOrchestrator(straightRouteService{}, nil, newTestPool(t), logging.Discard())
+
+		past := newTestSearch("past", montreal, quebec)
+		future := newTestSearch("future", montreal, quebec)
+		future.Filters.LeaveAt = time.Now().Add(time.Hour)
+
+		for _, s := range []*entity.Search{past, future} {
+			err := o.StartSearch(context.Background(), s, newFakeSubscription("search:"+s.ID.Hex()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer o.StopSearch(s.ID.Hex())
+		}
+
+		IDs := o.ExpiredSearchIDs(time.Now())
+		if len(IDs) != 1 || IDs[0] != "past" {
+			t.Errorf("expected only \"past\", got %v", IDs)
+		}
+	})
+}
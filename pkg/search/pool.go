@@ -0,0 +1,128 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"azure.com/ecovo/trip-search-service/pkg/metrics"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// A Pool is a bounded set of goroutines that evaluate trips for searches.
+//
+// Trips are queued along with the worker of the search they must be evaluated
+// for, and picked up by the first goroutine available. When the queue is full,
+// submitting a trip blocks until there is room for it, which slows down the
+// producers instead of letting the queue grow without bounds.
+type Pool struct {
+	jobs chan job
+	wg   sync.WaitGroup
+
+	// stopping is closed when the pool stops, so that the submissions blocked
+	// on a full queue give up and release mu.
+	stopping chan struct{}
+	stopOnce sync.Once
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type job struct {
+	worker *Worker
+	trip   routedTrip
+	span   trace.SpanContext
+}
+
+const (
+	// DefaultPoolConcurrency represents the default number of goroutines
+	// evaluating trips at once.
+	DefaultPoolConcurrency = 16
+
+	// DefaultPoolQueueSize represents the default number of trips that can
+	// wait to be evaluated before submitting more blocks.
+	DefaultPoolQueueSize = 1024
+)
+
+// NewPool creates a pool with the given number of goroutines evaluating trips
+// and room for the given number of trips waiting to be evaluated, and starts
+// it.
+func NewPool(concurrency int, queueSize int) (*Pool, error) {
+	if concurrency <= 0 {
+		return nil, fmt.Errorf("search.Pool: concurrency must be greater than 0")
+	}
+
+	if queueSize < 0 {
+		return nil, fmt.Errorf("search.Pool: queue size cannot be negative")
+	}
+
+	p := &Pool{jobs: make(chan job, queueSize), stopping: make(chan struct{})}
+
+	p.wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go p.run()
+	}
+
+	return p, nil
+}
+
+// Submit queues a trip to be evaluated by the worker. It blocks while the
+// queue is full, until the context is done, the worker is stopped or the pool
+// is stopped. The span found in the context becomes the parent of the
+// evaluation's span.
+func (p *Pool) Submit(ctx context.Context, w *Worker, t routedTrip) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return fmt.Errorf("search.Pool: cannot submit to a stopped pool")
+	}
+
+	select {
+	case p.jobs <- job{w, t, trace.SpanContextFromContext(ctx)}:
+		metrics.PoolQueueDepth.Set(float64(len(p.jobs)))
+		return nil
+	case <-w.ctx.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.stopping:
+		return fmt.Errorf("search.Pool: cannot submit to a stopped pool")
+	}
+}
+
+// Stop stops accepting trips and waits for the ones that are queued to be
+// evaluated, or until the context is done. The submissions blocked on a full
+// queue fail instead of holding it up.
+func (p *Pool) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stopping) })
+
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("search.Pool: gave up waiting for queued trips to be evaluated (%s)", ctx.Err())
+	}
+}
+
+func (p *Pool) run() {
+	defer p.wg.Done()
+
+	for j := range p.jobs {
+		metrics.PoolQueueDepth.Set(float64(len(p.jobs)))
+		j.worker.evaluate(j.span, j.trip)
+	}
+}
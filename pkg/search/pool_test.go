@@ -0,0 +1,167 @@
+package search
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/pubsub/subscription"
+)
+
+// blockingSubscription blocks every publish until it is released, and keeps
+// track of how many publishes are in flight at once.
+type blockingSubscription struct {
+	release  chan struct{}
+	inFlight int32
+	maxSeen  int32
+}
+
+func (s *blockingSubscription) Publish(ctx context.Context, msg *subscription.Message) error {
+	n := atomic.AddInt32(&s.inFlight, 1)
+	for {
+		max := atomic.LoadInt32(&s.maxSeen)
+		if n <= max || atomic.CompareAndSwapInt32(&s.maxSeen, max, n) {
+			break
+		}
+	}
+
+	<-s.release
+
+	atomic.AddInt32(&s.inFlight, -1)
+
+	return nil
+}
+
+func (s *blockingSubscription) Subscribe(callback subscription.Callback) error {
+	return nil
+}
+
+func (s *blockingSubscription) Topic() string {
+	return "search:blocking"
+}
+
+func TestPool(t *testing.T) {
+	search := newTestSearch("search", montreal, quebec)
+	trip := routedTrip{newTestTrip("1", montreal, quebec), newTestRoute(montreal, quebec)}
+
+	t.Run("Should not evaluate more trips at once than its concurrency", func(t *testing.T) {
+		pool, err := NewPool(2, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sub := &blockingSubscription{release: make(chan struct{})}
+		w, err := NewWorker(search.Filters, sub, nil, logging.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Start(context.Background())
+
+		for i := 0; i < 5; i++ {
+			err := pool.Submit(context.Background(), w, trip)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		close(sub.release)
+
+		err = pool.Stop(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sub.maxSeen != 2 {
+			t.Errorf("expected 2 trips evaluated at once, got %d", sub.maxSeen)
+		}
+	})
+
+	t.Run("Should block submissions while the queue is full", func(t *testing.T) {
+		pool, err := NewPool(1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sub := &blockingSubscription{release: make(chan struct{})}
+		defer close(sub.release)
+
+		w, err := NewWorker(search.Filters, sub, nil, logging.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Start(context.Background())
+
+		// The first trip is picked up and blocks, the second one fills the
+		// queue.
+		for i := 0; i < 2; i++ {
+			err := pool.Submit(context.Background(), w, trip)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		err = pool.Submit(ctx, w, trip)
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected submission to time out, got %v", err)
+		}
+	})
+
+	t.Run("Should stop without waiting for the submissions blocked on a full queue", func(t *testing.T) {
+		pool, err := NewPool(1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sub := &blockingSubscription{release: make(chan struct{})}
+		defer close(sub.release)
+
+		w, err := NewWorker(search.Filters, sub, nil, logging.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Start(context.Background())
+
+		// The first trip is picked up and blocks, so the second one waits
+		// for room in the queue.
+		err = pool.Submit(context.Background(), w, trip)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		submitted := make(chan error, 1)
+		go func() {
+			submitted <- pool.Submit(context.Background(), w, trip)
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- pool.Stop(ctx)
+		}()
+
+		select {
+		case err := <-stopped:
+			if err == nil {
+				t.Error("expected to give up waiting for the trip being evaluated")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the pool to stop by the deadline")
+		}
+
+		if err := <-submitted; err == nil {
+			t.Error("expected the blocked submission to fail")
+		}
+	})
+}
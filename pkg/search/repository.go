@@ -1,11 +1,57 @@
 package search
 
-import "azure.com/ecovo/trip-search-service/pkg/entity"
+import (
+	"context"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+)
+
+// A Summary describes what happened to a search while it ran.
+type Summary struct {
+	Search *entity.Search
+
+	// CreatedAt represents when the search was created.
+	CreatedAt time.Time
+
+	// Results represents how many distinct trips were found for the search,
+	// up to MaxResults.
+	Results int
+}
+
+// MaxResults represents how many distinct trips are recorded for a search at
+// most, so that its document does not grow with every result. Knowing that a
+// search found that many trips is enough to tell the demand was met.
+const MaxResults = 100
 
 // Repository is an interface representing the ability to perform CRUD
 // operations on searches in a database.
+//
+// The errors returned by repositories wrap ErrNotFound when no search has the
+// given ID, ErrInvalidID when the ID is not in the expected format and
+// ErrUnavailable when the database fails.
 type Repository interface {
-	FindByID(ID entity.ID) (*entity.Search, error)
-	Create(trip *entity.Search) (entity.ID, error)
-	Delete(ID entity.ID) error
+	FindByID(ctx context.Context, ID entity.ID) (*entity.Search, error)
+	Create(ctx context.Context, trip *entity.Search, lease *Lease) (entity.ID, error)
+
+	// Delete removes the search with the given ID, and returns its summary.
+	Delete(ctx context.Context, ID entity.ID) (*Summary, error)
+
+	// AddResult records that the trip was found for the search. Recording
+	// the same trip more than once counts it once, and the trips found once
+	// MaxResults were recorded are not counted.
+	AddResult(ctx context.Context, ID entity.ID, tripID entity.ID) error
+
+	// RenewLeases extends the leases held by the lease's owner until the
+	// lease's expiry, and returns the IDs of the searches it holds.
+	RenewLeases(ctx context.Context, lease *Lease) ([]entity.ID, error)
+
+	// AcquireExpiredLease gives the lease of a search whose lease has expired
+	// to the lease's owner, and returns the search. It returns nil when no
+	// lease has expired.
+	AcquireExpiredLease(ctx context.Context, lease *Lease) (*entity.Search, error)
+
+	// ReleaseLeases gives up the leases held by the owner and marks their
+	// searches resumable, so that other instances take them over right away.
+	ReleaseLeases(ctx context.Context, owner string) error
 }
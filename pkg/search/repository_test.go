@@ -0,0 +1,347 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"github.com/mongodb/mongo-go-driver/bson/primitive"
+	"github.com/mongodb/mongo-go-driver/mongo"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) Repository {
+		r, err := NewMemoryRepository()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	})
+}
+
+// TestMongoRepository runs against the MongoDB server given by TEST_MONGO_URI,
+// in a database created for the test, and is skipped when it is not defined.
+func TestMongoRepository(t *testing.T) {
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not defined")
+	}
+
+	client, err := mongo.NewClient(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+
+	testRepository(t, func(t *testing.T) Repository {
+		db := client.Database(fmt.Sprintf("trip_search_test_%s", primitive.NewObjectID().Hex()))
+		t.Cleanup(func() {
+			db.Drop(context.Background())
+		})
+
+		r, err := NewMongoRepository(db.Collection(searchCollectionName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	})
+}
+
+const searchCollectionName = "searches"
+
+// testRepository checks that a repository behaves as the Repository interface
+// describes. newRepository must return an empty repository every time.
+func testRepository(t *testing.T, newRepository func(t *testing.T) Repository) {
+	ctx := context.Background()
+	seats := 2
+
+	newSearch := func() *entity.Search {
+		return &entity.Search{Filters: &entity.Filters{
+			Seats:       &seats,
+			LeaveAt:     time.Date(2026, 10, 23, 17, 0, 0, 0, time.UTC),
+			Source:      &entity.Point{Latitude: 45.5017, Longitude: -73.5673},
+			Destination: &entity.Point{Latitude: 46.8139, Longitude: -71.2080},
+		}}
+	}
+
+	create := func(t *testing.T, r Repository, lease *Lease) entity.ID {
+		ID, err := r.Create(ctx, newSearch(), lease)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ID
+	}
+
+	t.Run("Should find a created search by its ID", func(t *testing.T) {
+		r := newRepository(t)
+
+		ID := create(t, r, &Lease{Owner: "a", ExpiresAt: time.Now().Add(time.Minute)})
+		if _, err := primitive.ObjectIDFromHex(ID.Hex()); err != nil {
+			t.Fatalf("expected an object ID, got \"%s\"", ID)
+		}
+
+		s, err := r.FindByID(ctx, ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.ID != ID {
+			t.Errorf("expected ID %s, got %s", ID, s.ID)
+		}
+		if s.Filters == nil || s.Filters.Seats == nil || *s.Filters.Seats != seats {
+			t.Errorf("expected filters with %d seats, got %+v", seats, s.Filters)
+		}
+		if !s.Filters.LeaveAt.Equal(newSearch().Filters.LeaveAt) {
+			t.Errorf("expected leave at %s, got %s", newSearch().Filters.LeaveAt, s.Filters.LeaveAt)
+		}
+	})
+
+	t.Run("Should keep the callback of a search", func(t *testing.T) {
+		r := newRepository(t)
+
+		search := newSearch()
+		search.CallbackURL = "https://partner.example.com/results"
+		search.CallbackSecret = "secret"
+		ID, err := r.Create(ctx, search, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := r.FindByID(ctx, ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.CallbackURL != search.CallbackURL || s.CallbackSecret != search.CallbackSecret {
+			t.Errorf("expected callback %s with its secret, got %+v", search.CallbackURL, s)
+		}
+	})
+
+	t.Run("Should fail to find a search that does not exist", func(t *testing.T) {
+		r := newRepository(t)
+
+		_, err := r.FindByID(ctx, entity.ID(primitive.NewObjectID().Hex()))
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Should fail to find a search with an invalid ID", func(t *testing.T) {
+		r := newRepository(t)
+
+		_, err := r.FindByID(ctx, "not-an-id")
+		if !errors.Is(err, ErrInvalidID) {
+			t.Errorf("expected ErrInvalidID, got %v", err)
+		}
+
+		_, err = r.Delete(ctx, "not-an-id")
+		if !errors.Is(err, ErrInvalidID) {
+			t.Errorf("expected ErrInvalidID, got %v", err)
+		}
+	})
+
+	t.Run("Should not find a deleted search", func(t *testing.T) {
+		r := newRepository(t)
+		ID := create(t, r, nil)
+
+		_, err := r.Delete(ctx, ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = r.FindByID(ctx, ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+
+		_, err = r.Delete(ctx, ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound when deleting again, got %v", err)
+		}
+	})
+
+	t.Run("Should count distinct results in the summary of a deleted search", func(t *testing.T) {
+		r := newRepository(t)
+		before := time.Now().Add(-time.Second)
+		ID := create(t, r, nil)
+
+		for _, tripID := range []entity.ID{"trip1", "trip2", "trip1"} {
+			err := r.AddResult(ctx, ID, tripID)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		summary, err := r.Delete(ctx, ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if summary.Results != 2 {
+			t.Errorf("expected 2 results, got %d", summary.Results)
+		}
+		if summary.CreatedAt.Before(before) {
+			t.Errorf("expected creation after %s, got %s", before, summary.CreatedAt)
+		}
+		if summary.Search == nil || summary.Search.ID != ID || summary.Search.Filters == nil {
+			t.Errorf("expected search %s with filters, got %+v", ID, summary.Search)
+		}
+
+		err = r.AddResult(ctx, ID, "trip3")
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Should stop counting results once the maximum is recorded", func(t *testing.T) {
+		r := newRepository(t)
+		ID := create(t, r, nil)
+
+		for i := 0; i <= MaxResults; i++ {
+			err := r.AddResult(ctx, ID, entity.ID(fmt.Sprintf("trip%d", i)))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		summary, err := r.Delete(ctx, ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if summary.Results != MaxResults {
+			t.Errorf("expected %d results, got %d", MaxResults, summary.Results)
+		}
+	})
+
+	t.Run("Should renew only the leases held by the owner", func(t *testing.T) {
+		r := newRepository(t)
+		expiresAt := time.Now().Add(time.Minute)
+		a1 := create(t, r, &Lease{Owner: "a", ExpiresAt: expiresAt})
+		a2 := create(t, r, &Lease{Owner: "a", ExpiresAt: expiresAt})
+		create(t, r, &Lease{Owner: "b", ExpiresAt: expiresAt})
+
+		IDs, err := r.RenewLeases(ctx, &Lease{Owner: "a", ExpiresAt: expiresAt.Add(time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []entity.ID{a1, a2}
+		sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+		sort.Slice(IDs, func(i, j int) bool { return IDs[i] < IDs[j] })
+		if fmt.Sprint(IDs) != fmt.Sprint(expected) {
+			t.Errorf("expected IDs %v, got %v", expected, IDs)
+		}
+	})
+
+	t.Run("Should acquire an expired lease once", func(t *testing.T) {
+		r := newRepository(t)
+		ID := create(t, r, &Lease{Owner: "a", ExpiresAt: time.Now().Add(-time.Second)})
+		create(t, r, &Lease{Owner: "a", ExpiresAt: time.Now().Add(time.Minute)})
+
+		s, err := r.AcquireExpiredLease(ctx, &Lease{Owner: "b", ExpiresAt: time.Now().Add(time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s == nil || s.ID != ID {
+			t.Fatalf("expected search %s, got %+v", ID, s)
+		}
+
+		s, err = r.AcquireExpiredLease(ctx, &Lease{Owner: "c", ExpiresAt: time.Now().Add(time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != nil {
+			t.Errorf("expected no search, got %s", s.ID)
+		}
+
+		IDs, err := r.RenewLeases(ctx, &Lease{Owner: "b", ExpiresAt: time.Now().Add(time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(IDs) != 1 || IDs[0] != ID {
+			t.Errorf("expected b to hold %s, got %v", ID, IDs)
+		}
+	})
+
+	t.Run("Should make released searches available to other owners", func(t *testing.T) {
+		r := newRepository(t)
+		ID := create(t, r, &Lease{Owner: "a", ExpiresAt: time.Now().Add(time.Minute)})
+
+		err := r.ReleaseLeases(ctx, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		IDs, err := r.RenewLeases(ctx, &Lease{Owner: "a", ExpiresAt: time.Now().Add(time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(IDs) != 0 {
+			t.Errorf("expected a to hold no searches, got %v", IDs)
+		}
+
+		s, err := r.AcquireExpiredLease(ctx, &Lease{Owner: "b", ExpiresAt: time.Now().Add(time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s == nil || s.ID != ID {
+			t.Errorf("expected search %s, got %+v", ID, s)
+		}
+	})
+
+	t.Run("Should not change stored searches through entities", func(t *testing.T) {
+		r := newRepository(t)
+		ID := create(t, r, nil)
+
+		s, err := r.FindByID(ctx, ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Filters.LeaveAt = time.Time{}
+
+		s, err = r.FindByID(ctx, ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Filters.LeaveAt.IsZero() {
+			t.Error("expected the stored search to be unchanged")
+		}
+	})
+
+	t.Run("Should not change stored searches through the created entity", func(t *testing.T) {
+		r := newRepository(t)
+
+		search := newSearch()
+		n := seats
+		search.Filters.Seats = &n
+
+		ID, err := r.Create(ctx, search, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*search.Filters.Seats = 4
+		search.Filters.Source.Latitude = 0
+
+		s, err := r.FindByID(ctx, ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *s.Filters.Seats != seats || s.Filters.Source.Latitude != newSearch().Filters.Source.Latitude {
+			t.Errorf("expected the stored search to be unchanged, got %d seats from %+v", *s.Filters.Seats, s.Filters.Source)
+		}
+	})
+}
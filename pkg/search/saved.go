@@ -0,0 +1,297 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/metrics"
+	"azure.com/ecovo/trip-search-service/pkg/notification"
+	"googlemaps.github.io/maps"
+)
+
+// SavedUseCase is an interface representing the ability to handle the
+// business logic that involves saved searches.
+type SavedUseCase interface {
+	Create(ctx context.Context, s *entity.SavedSearch) (*entity.SavedSearch, error)
+	FindByUserID(ctx context.Context, userID string) ([]*entity.SavedSearch, error)
+
+	// Delete removes the user's saved search. It returns a NotFoundError when
+	// the user has no saved search with the given ID.
+	Delete(ctx context.Context, userID string, ID entity.ID) error
+
+	// Match evaluates a trip that was added, along with the points of its
+	// route, against the saved searches, and notifies the users whose saved
+	// searches it matches.
+	Match(ctx context.Context, t *entity.Trip, points []maps.LatLng)
+}
+
+const (
+	// MaxSavedSearchHorizon represents how far in the future riders can want
+	// to travel when they save a search.
+	MaxSavedSearchHorizon = 90 * 24 * time.Hour
+
+	// savedSearchRefreshInterval represents how often the saved searches are
+	// loaded again from the repository, to find the ones saved or deleted on
+	// other instances.
+	savedSearchRefreshInterval = time.Minute
+)
+
+// A SavedService handles the business logic related to saved searches.
+//
+// The saved searches are kept in a spatial index, like the searches run by
+// workers, so that a trip is only evaluated against the saved searches whose
+// source and destination are close to its route. Every instance evaluates the
+// trips against every saved search, but the repository makes sure that users
+// are notified once.
+type SavedService struct {
+	repo     SavedRepository
+	notifier notification.Notifier
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	index    *spatialIndex
+	searches map[string]*entity.SavedSearch
+	loadedAt time.Time
+	loading  bool
+}
+
+// NewSavedService creates a saved search service to handle business logic and
+// manipulate saved searches through a repository. The users are notified
+// through the notifier.
+func NewSavedService(repo SavedRepository, notifier notification.Notifier, logger *slog.Logger) (SavedUseCase, error) {
+	if repo == nil {
+		return nil, fmt.Errorf("search.SavedService: repository is nil")
+	}
+
+	if notifier == nil {
+		return nil, fmt.Errorf("search.SavedService: notifier is nil")
+	}
+
+	if logger == nil {
+		return nil, fmt.Errorf("search.SavedService: logger is nil")
+	}
+
+	return &SavedService{
+		repo:     repo,
+		notifier: notifier,
+		logger:   logger,
+		index:    newSpatialIndex(),
+		searches: make(map[string]*entity.SavedSearch),
+	}, nil
+}
+
+// Create validates the saved search's information and saves it. The time the
+// rider wants to travel must be in the future, within MaxSavedSearchHorizon.
+func (s *SavedService) Create(ctx context.Context, saved *entity.SavedSearch) (*entity.SavedSearch, error) {
+	if saved == nil {
+		return nil, fmt.Errorf("search.SavedService: saved search is nil")
+	}
+
+	if saved.UserID == "" {
+		return nil, fmt.Errorf("search.SavedService: saved search has no user")
+	}
+
+	err := saved.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	travelAt := saved.Filters.TravelAt()
+	if !travelAt.After(now) {
+		return nil, entity.NewValidationError("leaveAt, arriveBy, the time window or the last occurrence must be in the future")
+	} else if travelAt.Sub(now) > MaxSavedSearchHorizon {
+		return nil, entity.NewValidationError(fmt.Sprintf("leaveAt, arriveBy, the time window or the last occurrence must be within %d days", MaxSavedSearchHorizon/(24*time.Hour)))
+	}
+
+	saved.CreatedAt = now.UTC()
+	saved.ID, err = s.repo.Create(ctx, saved)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.add(saved)
+	s.mu.Unlock()
+
+	s.logger.InfoContext(logging.WithSearchID(ctx, saved.ID.Hex()), "search saved")
+
+	return saved, nil
+}
+
+// FindByUserID retrieves the saved searches of the user, from the oldest.
+func (s *SavedService) FindByUserID(ctx context.Context, userID string) ([]*entity.SavedSearch, error) {
+	return s.repo.FindByUserID(ctx, userID)
+}
+
+// Delete removes the user's saved search. It returns a NotFoundError when the
+// user has no saved search with the given ID.
+func (s *SavedService) Delete(ctx context.Context, userID string, ID entity.ID) error {
+	err := s.repo.Delete(ctx, userID, ID)
+	if errors.Is(err, ErrNotFound) {
+		return NotFoundError{err.Error()}
+	} else if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.remove(ID.Hex())
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Match evaluates a trip that was added against the saved searches close to
+// its route, and notifies the users whose saved searches it matches and who
+// were not notified of it yet.
+func (s *SavedService) Match(ctx context.Context, t *entity.Trip, points []maps.LatLng) {
+	if t == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, saved := range s.candidates(ctx, points, now) {
+		searchCtx := logging.WithSearchID(ctx, saved.ID.Hex())
+
+		if !saved.Filters.TravelAt().After(now) {
+			continue
+		}
+
+		reason := rejectReason(t, saved.Filters, points)
+		if reason != metrics.ReasonNone {
+			s.logger.DebugContext(searchCtx, "trip rejected by saved search", "reason", reason)
+			continue
+		}
+
+		first, err := s.repo.MarkNotified(ctx, saved.ID, t.ID)
+		if errors.Is(err, ErrNotFound) {
+			// The saved search was deleted on another instance.
+			s.mu.Lock()
+			s.remove(saved.ID.Hex())
+			s.mu.Unlock()
+			continue
+		} else if err != nil {
+			s.logger.ErrorContext(searchCtx, "failed to mark saved search notified", "error", err)
+			continue
+		} else if !first {
+			continue
+		}
+
+		err = s.notifier.Notify(ctx, &notification.Notification{
+			Type:          notification.EventSavedSearchMatched,
+			UserID:        saved.UserID,
+			SavedSearchID: saved.ID,
+			Trip:          t,
+			CreatedAt:     now.UTC(),
+		})
+		if err != nil {
+			s.logger.ErrorContext(searchCtx, "failed to notify user of saved search match", "error", err)
+
+			// The trip is only marked to make sure a single instance
+			// notifies the user, so it is unmarked for them to be
+			// notified of it again.
+			err = s.repo.UnmarkNotified(ctx, saved.ID, t.ID)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				s.logger.ErrorContext(searchCtx, "failed to unmark saved search notified", "error", err)
+			}
+			continue
+		}
+
+		s.logger.InfoContext(searchCtx, "user notified of saved search match")
+	}
+}
+
+// candidates returns the saved searches whose source and destination are
+// close to the points. The saved searches are loaded again from the
+// repository first when they were loaded more than
+// savedSearchRefreshInterval ago.
+func (s *SavedService) candidates(ctx context.Context, points []maps.LatLng, now time.Time) []*entity.SavedSearch {
+	s.mu.Lock()
+	refresh := !s.loading && now.Sub(s.loadedAt) >= savedSearchRefreshInterval
+	if refresh {
+		s.loading = true
+	}
+	s.mu.Unlock()
+
+	if refresh {
+		s.load(ctx, now)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var candidates []*entity.SavedSearch
+	for _, id := range s.index.candidates(points) {
+		if saved, ok := s.searches[id]; ok {
+			candidates = append(candidates, saved)
+		}
+	}
+
+	return candidates
+}
+
+// load replaces the saved searches with the ones in the repository. They are
+// loaded without holding the mutex, so that the trips keep being matched
+// against the ones already loaded in the meantime, which are kept when the
+// repository fails. It is tried again next time.
+//
+// The saved searches created or deleted on this instance while they are
+// loaded may be missed until the next time, like the ones created or deleted
+// on other instances.
+func (s *SavedService) load(ctx context.Context, now time.Time) {
+	searches, err := s.repo.FindAll(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to load saved searches", "error", err)
+
+		s.mu.Lock()
+		s.loading = false
+		s.mu.Unlock()
+		return
+	}
+
+	index := newSpatialIndex()
+	byID := make(map[string]*entity.SavedSearch, len(searches))
+	for _, saved := range searches {
+		id := saved.ID.Hex()
+
+		byID[id] = saved
+		index.add(id, saved.Filters)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.index = index
+	s.searches = byID
+	s.loadedAt = now
+	s.loading = false
+}
+
+// add adds the saved search to the index. It must be called with the mutex
+// held.
+func (s *SavedService) add(saved *entity.SavedSearch) {
+	id := saved.ID.Hex()
+
+	s.searches[id] = saved
+	s.index.add(id, saved.Filters)
+}
+
+// remove removes the saved search from the index. It must be called with the
+// mutex held.
+func (s *SavedService) remove(id string) {
+	delete(s.searches, id)
+	s.index.remove(id)
+}
+
+// sortSavedSearches sorts saved searches from the oldest. The IDs start with
+// their creation time, so they are sorted by ID.
+func sortSavedSearches(searches []*entity.SavedSearch) {
+	sort.Slice(searches, func(i, j int) bool { return searches[i].ID < searches[j].ID })
+}
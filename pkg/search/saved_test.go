@@ -0,0 +1,329 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/notification"
+	"github.com/mongodb/mongo-go-driver/bson/primitive"
+	"github.com/mongodb/mongo-go-driver/mongo"
+	"googlemaps.github.io/maps"
+)
+
+func TestSavedMemoryRepository(t *testing.T) {
+	testSavedRepository(t, func(t *testing.T) SavedRepository {
+		r, err := NewSavedMemoryRepository()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	})
+}
+
+// TestSavedMongoRepository runs against the MongoDB server given by
+// TEST_MONGO_URI, in a database created for the test, and is skipped when it
+// is not defined.
+func TestSavedMongoRepository(t *testing.T) {
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not defined")
+	}
+
+	client, err := mongo.NewClient(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+
+	testSavedRepository(t, func(t *testing.T) SavedRepository {
+		db := client.Database(fmt.Sprintf("trip_search_test_%s", primitive.NewObjectID().Hex()))
+		t.Cleanup(func() {
+			db.Drop(context.Background())
+		})
+
+		r, err := NewSavedMongoRepository(db.Collection("savedSearches"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	})
+}
+
+func newTestSavedSearch(userID string, source, destination *entity.Point, leaveAt time.Time) *entity.SavedSearch {
+	return &entity.SavedSearch{
+		UserID:    userID,
+		Filters:   &entity.Filters{Source: source, Destination: destination, LeaveAt: leaveAt},
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+}
+
+// testSavedRepository checks that a repository behaves as the SavedRepository
+// interface describes. newRepository must return an empty repository every
+// time.
+func testSavedRepository(t *testing.T, newRepository func(t *testing.T) SavedRepository) {
+	ctx := context.Background()
+	friday := time.Now().Add(72 * time.Hour).UTC().Truncate(time.Millisecond)
+
+	t.Run("Should only find the saved searches of the user", func(t *testing.T) {
+		r := newRepository(t)
+
+		var IDs []entity.ID
+		for _, userID := range []string{"alice", "bob", "alice"} {
+			ID, err := r.Create(ctx, newTestSavedSearch(userID, montreal, quebec, friday))
+			if err != nil {
+				t.Fatal(err)
+			}
+			IDs = append(IDs, ID)
+		}
+
+		searches, err := r.FindByUserID(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(searches) != 2 || searches[0].ID != IDs[0] || searches[1].ID != IDs[2] {
+			t.Fatalf("expected the saved searches of alice from the oldest, got %v", searches)
+		}
+		if !searches[0].Filters.LeaveAt.Equal(friday) {
+			t.Errorf("expected leaveAt %s, got %s", friday, searches[0].Filters.LeaveAt)
+		}
+	})
+
+	t.Run("Should not return the saved searches whose travel time has passed", func(t *testing.T) {
+		r := newRepository(t)
+
+		_, err := r.Create(ctx, newTestSavedSearch("alice", montreal, quebec, time.Now().Add(-time.Minute)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		searches, err := r.FindAll(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(searches) != 0 {
+			t.Errorf("expected no saved search, got %d", len(searches))
+		}
+	})
+
+	t.Run("Should only let the user delete their saved searches", func(t *testing.T) {
+		r := newRepository(t)
+
+		ID, err := r.Create(ctx, newTestSavedSearch("alice", montreal, quebec, friday))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = r.Delete(ctx, "bob", ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+
+		err = r.Delete(ctx, "alice", ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = r.Delete(ctx, "alice", ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Should only mark a trip notified once", func(t *testing.T) {
+		r := newRepository(t)
+
+		ID, err := r.Create(ctx, newTestSavedSearch("alice", montreal, quebec, friday))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tripID := entity.ID(primitive.NewObjectID().Hex())
+		for i, expected := range []bool{true, false} {
+			first, err := r.MarkNotified(ctx, ID, tripID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if first != expected {
+				t.Errorf("expected mark %d to be first %t, got %t", i, expected, first)
+			}
+		}
+
+		_, err = r.MarkNotified(ctx, entity.ID(primitive.NewObjectID().Hex()), tripID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Should mark a trip notified again once it is unmarked", func(t *testing.T) {
+		r := newRepository(t)
+
+		ID, err := r.Create(ctx, newTestSavedSearch("alice", montreal, quebec, friday))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tripID := entity.ID(primitive.NewObjectID().Hex())
+		_, err = r.MarkNotified(ctx, ID, tripID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = r.UnmarkNotified(ctx, ID, tripID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		first, err := r.MarkNotified(ctx, ID, tripID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !first {
+			t.Error("expected the trip to be marked notified again")
+		}
+	})
+}
+
+type fakeNotifier struct {
+	mu            sync.Mutex
+	notifications []*notification.Notification
+	err           error
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, notification *notification.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.err != nil {
+		return n.err
+	}
+
+	n.notifications = append(n.notifications, notification)
+
+	return nil
+}
+
+func routeOf(points ...*entity.Point) []maps.LatLng {
+	var path []maps.LatLng
+	for _, p := range points {
+		path = append(path, maps.LatLng{Lat: p.Latitude, Lng: p.Longitude})
+	}
+
+	return path
+}
+
+func TestSavedServiceMatch(t *testing.T) {
+	ctx := context.Background()
+	friday := time.Now().Add(72 * time.Hour)
+
+	newService := func(t *testing.T) (SavedUseCase, *fakeNotifier) {
+		repo, err := NewSavedMemoryRepository()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		notifier := &fakeNotifier{}
+		s, err := NewSavedService(repo, notifier, logging.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s, notifier
+	}
+
+	t.Run("Should notify the users whose saved searches match once", func(t *testing.T) {
+		s, notifier := newService(t)
+
+		saved, err := s.Create(ctx, newTestSavedSearch("alice", montreal, quebec, friday))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sherbrooke := &entity.Point{Latitude: 45.4042, Longitude: -71.8929}
+		_, err = s.Create(ctx, newTestSavedSearch("bob", sherbrooke, quebec, friday))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		trip := newTestTrip(primitive.NewObjectID().Hex(), montreal, drummondville, quebec)
+		s.Match(ctx, trip, routeOf(montreal, drummondville, quebec))
+		s.Match(ctx, trip, routeOf(montreal, drummondville, quebec))
+
+		if len(notifier.notifications) != 1 {
+			t.Fatalf("expected 1 notification, got %d", len(notifier.notifications))
+		}
+
+		n := notifier.notifications[0]
+		if n.UserID != "alice" || n.SavedSearchID != saved.ID || n.Trip != trip {
+			t.Errorf("expected alice to be notified of the trip for her saved search, got %+v", n)
+		}
+	})
+
+	t.Run("Should notify the users again when notifying them failed", func(t *testing.T) {
+		s, notifier := newService(t)
+
+		_, err := s.Create(ctx, newTestSavedSearch("alice", montreal, quebec, friday))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		notifier.err = errors.New("unavailable")
+
+		trip := newTestTrip(primitive.NewObjectID().Hex(), montreal, quebec)
+		s.Match(ctx, trip, routeOf(montreal, quebec))
+
+		notifier.err = nil
+		s.Match(ctx, trip, routeOf(montreal, quebec))
+
+		if len(notifier.notifications) != 1 {
+			t.Errorf("expected 1 notification, got %d", len(notifier.notifications))
+		}
+	})
+
+	t.Run("Should not notify the users of deleted saved searches", func(t *testing.T) {
+		s, notifier := newService(t)
+
+		saved, err := s.Create(ctx, newTestSavedSearch("alice", montreal, quebec, friday))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = s.Delete(ctx, "alice", saved.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.Match(ctx, newTestTrip(primitive.NewObjectID().Hex(), montreal, quebec), routeOf(montreal, quebec))
+
+		if len(notifier.notifications) != 0 {
+			t.Errorf("expected no notification, got %d", len(notifier.notifications))
+		}
+	})
+
+	t.Run("Should reject saved searches beyond the horizon", func(t *testing.T) {
+		s, _ := newService(t)
+
+		_, err := s.Create(ctx, newTestSavedSearch("alice", montreal, quebec, time.Now().Add(MaxSavedSearchHorizon+time.Hour)))
+
+		var validationErr entity.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("expected a validation error, got %v", err)
+		}
+	})
+}
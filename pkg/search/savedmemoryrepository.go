@@ -0,0 +1,167 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"github.com/mongodb/mongo-go-driver/bson/primitive"
+)
+
+// A SavedMemoryRepository is a repository that keeps saved searches in
+// memory. It behaves like a SavedMongoRepository, so that it can replace it in
+// tests and when running the service locally.
+//
+// It is safe for concurrent use, but the saved searches are lost when the
+// service stops and are not shared with other instances.
+type SavedMemoryRepository struct {
+	mu        sync.Mutex
+	documents map[primitive.ObjectID]*savedMemoryDocument
+}
+
+type savedMemoryDocument struct {
+	userID    string
+	filters   *entity.Filters
+	createdAt time.Time
+	notified  map[entity.ID]bool
+}
+
+// NewSavedMemoryRepository creates an empty in-memory saved search
+// repository.
+func NewSavedMemoryRepository() (SavedRepository, error) {
+	return &SavedMemoryRepository{documents: make(map[primitive.ObjectID]*savedMemoryDocument)}, nil
+}
+
+func (d *savedMemoryDocument) entity(id primitive.ObjectID) *entity.SavedSearch {
+	return &entity.SavedSearch{
+		ID:        entity.NewIDFromHex(id.Hex()),
+		UserID:    d.userID,
+		Filters:   copyFilters(d.filters),
+		CreatedAt: d.createdAt,
+	}
+}
+
+// expired returns whether the time the rider wanted to travel has passed.
+func (d *savedMemoryDocument) expired(now time.Time) bool {
+	return !d.filters.TravelAt().After(now)
+}
+
+// Create stores the saved search and returns the unique identifier that was
+// generated for it.
+func (r *SavedMemoryRepository) Create(ctx context.Context, s *entity.SavedSearch) (entity.ID, error) {
+	if s == nil || s.Filters == nil {
+		return entity.NilID, fmt.Errorf("search.SavedMemoryRepository: failed to create saved search (saved search or filters is nil)")
+	}
+
+	id := primitive.NewObjectID()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.documents[id] = &savedMemoryDocument{
+		userID:    s.UserID,
+		filters:   copyFilters(s.Filters),
+		createdAt: s.CreatedAt,
+		notified:  make(map[entity.ID]bool),
+	}
+
+	return entity.ID(id.Hex()), nil
+}
+
+// FindByUserID retrieves the saved searches of the user.
+func (r *SavedMemoryRepository) FindByUserID(ctx context.Context, userID string) ([]*entity.SavedSearch, error) {
+	return r.find(func(d *savedMemoryDocument) bool { return d.userID == userID }), nil
+}
+
+// FindAll retrieves every saved search.
+func (r *SavedMemoryRepository) FindAll(ctx context.Context) ([]*entity.SavedSearch, error) {
+	return r.find(func(d *savedMemoryDocument) bool { return true }), nil
+}
+
+// find retrieves the saved searches that have not expired and that match,
+// from the oldest.
+func (r *SavedMemoryRepository) find(match func(d *savedMemoryDocument) bool) []*entity.SavedSearch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	searches := []*entity.SavedSearch{}
+	for id, d := range r.documents {
+		if !d.expired(now) && match(d) {
+			searches = append(searches, d.entity(id))
+		}
+	}
+
+	sortSavedSearches(searches)
+
+	return searches
+}
+
+// Delete removes the saved search with the given ID, when it belongs to the
+// user.
+func (r *SavedMemoryRepository) Delete(ctx context.Context, userID string, ID entity.ID) error {
+	objectID, err := primitive.ObjectIDFromHex(string(ID))
+	if err != nil {
+		return fmt.Errorf("search.SavedMemoryRepository: %w \"%s\"", ErrInvalidID, ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.documents[objectID]
+	if !ok || d.userID != userID || d.expired(time.Now()) {
+		return fmt.Errorf("search.SavedMemoryRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	}
+
+	delete(r.documents, objectID)
+
+	return nil
+}
+
+// MarkNotified records that the user was notified of the trip for the saved
+// search, and returns whether it was the first time.
+func (r *SavedMemoryRepository) MarkNotified(ctx context.Context, ID entity.ID, tripID entity.ID) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(string(ID))
+	if err != nil {
+		return false, fmt.Errorf("search.SavedMemoryRepository: %w \"%s\"", ErrInvalidID, ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.documents[objectID]
+	if !ok || d.expired(time.Now()) {
+		return false, fmt.Errorf("search.SavedMemoryRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	}
+
+	if d.notified[tripID] {
+		return false, nil
+	}
+
+	d.notified[tripID] = true
+
+	return true, nil
+}
+
+// UnmarkNotified forgets that the user was notified of the trip for the saved
+// search, when notifying them failed after it was marked.
+func (r *SavedMemoryRepository) UnmarkNotified(ctx context.Context, ID entity.ID, tripID entity.ID) error {
+	objectID, err := primitive.ObjectIDFromHex(string(ID))
+	if err != nil {
+		return fmt.Errorf("search.SavedMemoryRepository: %w \"%s\"", ErrInvalidID, ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.documents[objectID]
+	if !ok {
+		return fmt.Errorf("search.SavedMemoryRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	}
+
+	delete(d.notified, tripID)
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/mongodb/mongo-go-driver/bson/primitive"
+	"github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/mongo/options"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// A SavedMongoRepository is a repository that performs CRUD operations on
+// saved searches in a MongoDB collection.
+//
+// The collection is expected to expire the documents once their travelAt has
+// passed, but they are never returned past that time either way.
+type SavedMongoRepository struct {
+	collection *mongo.Collection
+}
+
+type savedDocument struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"userId"`
+	Filters   *filtersDocument   `bson:"filters"`
+	TravelAt  time.Time          `bson:"travelAt"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	Notified  []string           `bson:"notified,omitempty"`
+}
+
+func (d savedDocument) Entity() *entity.SavedSearch {
+	return &entity.SavedSearch{
+		ID:        entity.NewIDFromHex(d.ID.Hex()),
+		UserID:    d.UserID,
+		Filters:   d.Filters.Entity(),
+		CreatedAt: d.CreatedAt,
+	}
+}
+
+// NewSavedMongoRepository creates a saved search repository for a MongoDB
+// collection.
+func NewSavedMongoRepository(collection *mongo.Collection) (SavedRepository, error) {
+	if collection == nil {
+		return nil, fmt.Errorf("search.SavedMongoRepository: collection is nil")
+	}
+
+	return &SavedMongoRepository{collection}, nil
+}
+
+// startOperation gives the database server operationTimeout to complete an
+// operation, and traces it. The returned function must be called with the
+// operation's error once it is done.
+func (r *SavedMongoRepository) startOperation(ctx context.Context, operation string) (context.Context, func(error)) {
+	ctx, span := tracer.Start(ctx, "mongo."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemMongoDB,
+		semconv.DBCollectionName(r.collection.Name()),
+		semconv.DBOperationName(operation),
+	))
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+
+	return ctx, func(err error) {
+		cancel()
+		tracing.End(span, err)
+	}
+}
+
+// notExpired returns the filter that excludes the saved searches whose travel
+// time has passed, which the database may not have removed yet.
+func notExpired() bson.E {
+	return bson.E{Key: "travelAt", Value: bson.D{{Key: "$gt", Value: time.Now()}}}
+}
+
+// Create stores the saved search in the database and returns the unique
+// identifier that was generated for it.
+func (r *SavedMongoRepository) Create(ctx context.Context, s *entity.SavedSearch) (_ entity.ID, err error) {
+	if s == nil || s.Filters == nil {
+		return entity.NilID, fmt.Errorf("search.SavedMongoRepository: failed to create saved search (saved search or filters is nil)")
+	}
+
+	ctx, done := r.startOperation(ctx, "insertOne")
+	defer func() { done(err) }()
+
+	res, err := r.collection.InsertOne(ctx, &savedDocument{
+		UserID:    s.UserID,
+		Filters:   newFiltersDocument(s.Filters),
+		TravelAt:  s.Filters.TravelAt(),
+		CreatedAt: s.CreatedAt,
+	})
+	if err != nil {
+		return entity.NilID, fmt.Errorf("search.SavedMongoRepository: failed to create saved search (%w)", unavailable(err))
+	}
+
+	ID, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return entity.NilID, fmt.Errorf("search.SavedMongoRepository: failed to get ID of created saved search")
+	}
+
+	return entity.ID(ID.Hex()), nil
+}
+
+// FindByUserID retrieves the saved searches of the user, from the oldest.
+func (r *SavedMongoRepository) FindByUserID(ctx context.Context, userID string) ([]*entity.SavedSearch, error) {
+	return r.find(ctx, bson.D{{Key: "userId", Value: userID}, notExpired()})
+}
+
+// FindAll retrieves every saved search, from the oldest.
+func (r *SavedMongoRepository) FindAll(ctx context.Context) ([]*entity.SavedSearch, error) {
+	return r.find(ctx, bson.D{notExpired()})
+}
+
+func (r *SavedMongoRepository) find(ctx context.Context, filter bson.D) (_ []*entity.SavedSearch, err error) {
+	ctx, done := r.startOperation(ctx, "find")
+	defer func() { done(err) }()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetProjection(bson.D{{Key: "notified", Value: 0}})
+	cur, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("search.SavedMongoRepository: failed to find saved searches (%w)", unavailable(err))
+	}
+	defer cur.Close(ctx)
+
+	searches := []*entity.SavedSearch{}
+	for cur.Next(ctx) {
+		var d savedDocument
+		err := cur.Decode(&d)
+		if err != nil {
+			return nil, fmt.Errorf("search.SavedMongoRepository: failed to decode saved search (%s)", err)
+		}
+
+		searches = append(searches, d.Entity())
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("search.SavedMongoRepository: failed to find saved searches (%w)", unavailable(err))
+	}
+
+	return searches, nil
+}
+
+// Delete removes the saved search with the given ID from the database, when
+// it belongs to the user.
+func (r *SavedMongoRepository) Delete(ctx context.Context, userID string, ID entity.ID) (err error) {
+	objectID, err := primitive.ObjectIDFromHex(string(ID))
+	if err != nil {
+		return fmt.Errorf("search.SavedMongoRepository: %w \"%s\"", ErrInvalidID, ID)
+	}
+
+	ctx, done := r.startOperation(ctx, "deleteOne")
+	defer func() { done(err) }()
+
+	filter := bson.D{{Key: "_id", Value: objectID}, {Key: "userId", Value: userID}, notExpired()}
+	res, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("search.SavedMongoRepository: failed to delete saved search with ID \"%s\" (%w)", ID, unavailable(err))
+	}
+
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("search.SavedMongoRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	}
+
+	return nil
+}
+
+// MarkNotified records that the user was notified of the trip for the saved
+// search, and returns whether it was the first time. The trip is only added
+// when it is not there yet, so that a single instance can be the first.
+func (r *SavedMongoRepository) MarkNotified(ctx context.Context, ID entity.ID, tripID entity.ID) (_ bool, err error) {
+	objectID, err := primitive.ObjectIDFromHex(string(ID))
+	if err != nil {
+		return false, fmt.Errorf("search.SavedMongoRepository: %w \"%s\"", ErrInvalidID, ID)
+	}
+
+	ctx, done := r.startOperation(ctx, "markNotified")
+	defer func() { done(err) }()
+
+	filter := bson.D{
+		{Key: "_id", Value: objectID},
+		{Key: "notified", Value: bson.D{{Key: "$ne", Value: tripID.Hex()}}},
+		notExpired(),
+	}
+	update := bson.D{{Key: "$push", Value: bson.D{{Key: "notified", Value: tripID.Hex()}}}}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("search.SavedMongoRepository: failed to mark saved search with ID \"%s\" notified (%w)", ID, unavailable(err))
+	}
+
+	if res.MatchedCount == 1 {
+		return true, nil
+	}
+
+	// Nothing was updated, either because the user was already notified or
+	// because the saved search does not exist.
+	count, err := r.collection.CountDocuments(ctx, bson.D{{Key: "_id", Value: objectID}, notExpired()})
+	if err != nil {
+		return false, fmt.Errorf("search.SavedMongoRepository: failed to find saved search with ID \"%s\" (%w)", ID, unavailable(err))
+	}
+
+	if count == 0 {
+		return false, fmt.Errorf("search.SavedMongoRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	}
+
+	return false, nil
+}
+
+// UnmarkNotified forgets that the user was notified of the trip for the saved
+// search, when notifying them failed after it was marked.
+func (r *SavedMongoRepository) UnmarkNotified(ctx context.Context, ID entity.ID, tripID entity.ID) (err error) {
+	objectID, err := primitive.ObjectIDFromHex(string(ID))
+	if err != nil {
+		return fmt.Errorf("search.SavedMongoRepository: %w \"%s\"", ErrInvalidID, ID)
+	}
+
+	ctx, done := r.startOperation(ctx, "unmarkNotified")
+	defer func() { done(err) }()
+
+	filter := bson.D{{Key: "_id", Value: objectID}}
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: "notified", Value: tripID.Hex()}}}}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("search.SavedMongoRepository: failed to unmark saved search with ID \"%s\" notified (%w)", ID, unavailable(err))
+	}
+
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("search.SavedMongoRepository: %w with ID \"%s\"", ErrNotFound, ID)
+	}
+
+	return nil
+}
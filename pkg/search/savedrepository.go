@@ -0,0 +1,41 @@
+package search
+
+import (
+	"context"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+)
+
+// SavedRepository is an interface representing the ability to perform CRUD
+// operations on saved searches in a database.
+//
+// A saved search is kept until the time its rider wanted to travel has
+// passed. Past that time, it is never returned, and the repository may
+// remove it.
+//
+// The errors returned by repositories wrap the same errors as those of a
+// Repository.
+type SavedRepository interface {
+	// Create stores the saved search and returns the unique identifier that
+	// was generated for it.
+	Create(ctx context.Context, s *entity.SavedSearch) (entity.ID, error)
+
+	// FindByUserID retrieves the saved searches of the user.
+	FindByUserID(ctx context.Context, userID string) ([]*entity.SavedSearch, error)
+
+	// FindAll retrieves every saved search.
+	FindAll(ctx context.Context) ([]*entity.SavedSearch, error)
+
+	// Delete removes the saved search with the given ID, when it belongs to
+	// the user.
+	Delete(ctx context.Context, userID string, ID entity.ID) error
+
+	// MarkNotified records that the user was notified of the trip for the
+	// saved search, and returns whether it was the first time, so that users
+	// are notified once even when several instances see the same trip.
+	MarkNotified(ctx context.Context, ID entity.ID, tripID entity.ID) (bool, error)
+
+	// UnmarkNotified forgets that the user was notified of the trip for the
+	// saved search, when notifying them failed after it was marked.
+	UnmarkNotified(ctx context.Context, ID entity.ID, tripID entity.ID) error
+}
@@ -1,132 +1,581 @@
 package search
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"azure.com/ecovo/trip-search-service/pkg/analytics"
 	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/metrics"
 	"azure.com/ecovo/trip-search-service/pkg/pubsub"
 	"azure.com/ecovo/trip-search-service/pkg/pubsub/subscription"
 	"azure.com/ecovo/trip-search-service/pkg/route"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
 	"azure.com/ecovo/trip-search-service/pkg/trip"
+	"azure.com/ecovo/trip-search-service/pkg/webhook"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"googlemaps.github.io/maps"
 )
 
 // UseCase is an interface representing the ability to handle the business
 // logic that involves searches for trips.
 type UseCase interface {
-	Create(search *entity.Search) (*entity.Search, error)
-	FindByID(ID entity.ID) (*entity.Search, error)
-	Delete(ID entity.ID) error
+	Create(ctx context.Context, search *entity.Search) (*entity.Search, error)
+	FindByID(ctx context.Context, ID entity.ID) (*entity.Search, error)
+
+	// Delete stops the search. Whether the rider stopped it because they
+	// booked a trip is recorded for analytics.
+	Delete(ctx context.Context, ID entity.ID, booked bool) error
+
+	// Deliveries retrieves the latest deliveries of the search's results to
+	// its callback URL, from the most recent.
+	Deliveries(ctx context.Context, ID entity.ID) ([]*webhook.Delivery, error)
+
+	// Shutdown stops searching for results gracefully, so the searches can be
+	// resumed by another instance.
+	Shutdown(ctx context.Context) error
 }
 
 // A Service handles the business logic related to searches for trips.
+//
+// Searches are shared between every instance of the service through leases
+// stored in the repository, so that any instance can handle requests for any
+// search, but only one runs its worker.
 type Service struct {
 	repo         Repository
 	pubSub       pubsub.UseCase
 	trip         trip.UseCase
+	analytics    analytics.UseCase
+	saved        SavedUseCase
+	webhooks     *webhook.Dispatcher
 	orchestrator *Orchestrator
+	demand       *demandAggregator
+	demandSub    subscription.Subscription
+	leases       *LeaseConfig
+	logger       *slog.Logger
+	stopLeases   context.CancelFunc
+	leasesDone   chan struct{}
+	shuttingDown int32
+
+	matchMu     sync.Mutex
+	matchSlots  chan struct{}
+	matching    sync.WaitGroup
+	matchClosed bool
 }
 
 const searchChannelPrefix = "search:"
 const tripsChannel = "trips"
 
+// maxConcurrentSavedMatches represents how many trips are matched against the
+// saved searches at once.
+const maxConcurrentSavedMatches = 16
+
 // NewService creates a search service to handle business logic and manipulate
 // searches through a repository.
-func NewService(repo Repository, pubSub pubsub.UseCase, trip trip.UseCase, routeService route.UseCase) (UseCase, error) {
-	orchestrator := NewOrchestrator(routeService)
+//
+// It also starts renewing the leases of the searches it runs, and taking over
+// the searches whose lease has expired, in the background. The searches are
+// recorded for analytics when they are deleted or expire, and the demand of
+// those that expired without finding any trip is published periodically.
+//
+// The trips that are added are also matched against the saved searches, and
+// the results of the searches that have a callback URL are delivered to it
+// through the webhooks dispatcher.
+func NewService(repo Repository, pubSub pubsub.UseCase, trip trip.UseCase, routeService route.UseCase, analytics analytics.UseCase, saved SavedUseCase, webhooks *webhook.Dispatcher, pool *Pool, leases *LeaseConfig, logger *slog.Logger) (UseCase, error) {
+	if analytics == nil {
+		return nil, fmt.Errorf("search.Service: analytics is nil")
+	}
+
+	if saved == nil {
+		return nil, fmt.Errorf("search.Service: saved searches are nil")
+	}
+
+	if webhooks == nil {
+		return nil, fmt.Errorf("search.Service: webhooks dispatcher is nil")
+	}
+
+	if pool == nil {
+		return nil, fmt.Errorf("search.Service: pool is nil")
+	}
+
+	if logger == nil {
+		return nil, fmt.Errorf("search.Service: logger is nil")
+	}
+
+	if leases == nil || leases.InstanceID == "" {
+		return nil, fmt.Errorf("search.Service: missing instance ID")
+	}
+
+	l := *leases
+	if l.TTL < 0 {
+		return nil, fmt.Errorf("search.Service: lease TTL cannot be negative")
+	} else if l.TTL == 0 {
+		l.TTL = DefaultLeaseTTL
+	}
+
+	orchestrator := NewOrchestrator(routeService, trip, pool, logger)
 	tripsSub, err := pubSub.Subscribe(tripsChannel)
 
 	if err != nil {
 		return nil, fmt.Errorf("trip.Service: error subscribing to channel (%s) ", err)
 	}
 
-	s := &Service{repo, pubSub, trip, orchestrator}
+	demandSub, err := pubSub.Subscribe(demandChannel)
+	if err != nil {
+		return nil, fmt.Errorf("search.Service: error subscribing to channel (%s)", err)
+	}
+
+	leasesCtx, stopLeases := context.WithCancel(context.Background())
+
+	s := &Service{
+		repo:         repo,
+		pubSub:       pubSub,
+		trip:         trip,
+		analytics:    analytics,
+		saved:        saved,
+		webhooks:     webhooks,
+		orchestrator: orchestrator,
+		demand:       newDemandAggregator(),
+		demandSub:    demandSub,
+		leases:       &l,
+		logger:       logger,
+		stopLeases:   stopLeases,
+		leasesDone:   make(chan struct{}),
+		matchSlots:   make(chan struct{}, maxConcurrentSavedMatches),
+	}
 
 	err = tripsSub.Subscribe(s.listenTripsChange)
 	if err != nil {
 		return nil, fmt.Errorf("trip.Service: error subscribing to channel (%s) ", err)
 	}
 
+	go func() {
+		s.maintainLeases(leasesCtx)
+		close(s.leasesDone)
+	}()
+
 	return s, nil
 }
 
 // Create validates the search's information, creates it, creates a
 // subscription and starts searching for results in the background that will be
 // published to the subscription.
-func (s *Service) Create(search *entity.Search) (*entity.Search, error) {
+//
+// It returns as soon as the search is started. The trips that already exist
+// are retrieved in the background and published as they come in.
+func (s *Service) Create(ctx context.Context, search *entity.Search) (_ *entity.Search, err error) {
+	ctx, span := tracer.Start(ctx, "search.Service.Create")
+	defer func() { tracing.End(span, err) }()
+
 	if search == nil {
-		return nil, fmt.Errorf("trip.Service: trip is nil")
+		return nil, entity.NewValidationError("search.Service: missing search")
 	}
 
-	err := search.Validate()
+	err = search.Validate()
 	if err != nil {
 		return nil, err
 	}
 
-	search.ID, err = s.repo.Create(search)
+	search.ID, err = s.repo.Create(ctx, search, s.leases.newLease())
 	if err != nil {
 		return nil, err
 	}
 
-	sub, err := s.pubSub.Subscribe(searchChannelPrefix + string(search.ID))
+	span.SetAttributes(attribute.String("search.id", search.ID.Hex()))
+
+	// The worker outlives the request, but keeps its values, like the request
+	// ID, so they reach the trip service during the backfill.
+	err = s.startWorker(context.WithoutCancel(ctx), search)
 	if err != nil {
+		_, _ = s.repo.Delete(ctx, search.ID)
 		return nil, err
 	}
 
-	err = s.orchestrator.StartSearch(search, sub)
-	if err != nil {
-		s.pubSub.Unsubscribe(searchChannelPrefix + search.ID.Hex())
-		_ = s.repo.Delete(search.ID)
+	s.logger.InfoContext(logging.WithSearchID(ctx, search.ID.Hex()), "search started")
+
+	return search, nil
+}
+
+// FindByID retrieves the search with the given ID in the repository, if it
+// exists. It returns a NotFoundError when it does not.
+func (s *Service) FindByID(ctx context.Context, ID entity.ID) (*entity.Search, error) {
+	search, err := s.repo.FindByID(ctx, ID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, NotFoundError{err.Error()}
+	} else if err != nil {
 		return nil, err
 	}
 
-	trips, err := s.trip.Find(search.Filters)
+	return search, nil
+}
+
+// Delete erases the search from the repository, stops searching for results
+// and records the search for analytics. It returns a NotFoundError when the
+// search does not exist.
+//
+// When another instance runs the search, it stops its worker as soon as it
+// notices that the search no longer exists, the next time it renews its
+// leases.
+func (s *Service) Delete(ctx context.Context, ID entity.ID, booked bool) error {
+	summary, err := s.end(ctx, ID, booked)
+	if errors.Is(err, ErrNotFound) {
+		return NotFoundError{err.Error()}
+	} else if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(logging.WithSearchID(ctx, ID.Hex()), "search stopped", "results", summary.Results, "booked", booked)
+
+	return nil
+}
+
+// Deliveries retrieves the latest deliveries of the search's results to its
+// callback URL, from the most recent, up to maxDeliveries. They are kept for a
+// while after the search ends. It returns a NotFoundError when there are none
+// and the search does not exist.
+func (s *Service) Deliveries(ctx context.Context, ID entity.ID) ([]*webhook.Delivery, error) {
+	deliveries, err := s.webhooks.Deliveries(ctx, ID.Hex(), maxDeliveries)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, t := range trips {
-		s.orchestrator.PublishTrip(t)
+	if len(deliveries) == 0 {
+		_, err = s.FindByID(ctx, ID)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return search, nil
+	return deliveries, nil
 }
 
-// FindByID retrieves the search with the given ID in the repository, if it
-// exists.
-func (s *Service) FindByID(ID entity.ID) (*entity.Search, error) {
-	search, err := s.repo.FindByID(ID)
+// end erases the search from the repository, stops its worker if it runs on
+// this instance and records the search for analytics.
+//
+// The worker is only stopped once the search is erased, so that a search that
+// could not be erased keeps running on the instance that holds its lease.
+func (s *Service) end(ctx context.Context, ID entity.ID, booked bool) (*Summary, error) {
+	summary, err := s.repo.Delete(ctx, ID)
+	if errors.Is(err, ErrNotFound) {
+		s.stopWorker(ID.Hex())
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	s.stopWorker(ID.Hex())
+
+	// The search is already gone, so failing to record it must not fail the
+	// search's end.
+	err = s.analytics.Record(ctx, &analytics.Record{
+		SearchID:  ID,
+		Filters:   summary.Search.Filters,
+		StartedAt: summary.CreatedAt,
+		EndedAt:   time.Now().UTC(),
+		Results:   summary.Results,
+		Booked:    booked,
+	})
 	if err != nil {
-		return nil, NotFoundError{err.Error()}
+		s.logger.WarnContext(logging.WithSearchID(ctx, ID.Hex()), "failed to record search for analytics", "error", err)
 	}
 
-	return search, nil
+	return summary, nil
 }
 
-// Delete erases the search from the repository, and stops searching for
-// results.
-func (s *Service) Delete(ID entity.ID) error {
-	s.orchestrator.StopSearch(ID.Hex())
+// expireSearches ends the searches run by this instance whose travel time has
+// passed. The demand of those that found no trip is kept to be published.
+func (s *Service) expireSearches(ctx context.Context) {
+	for _, id := range s.orchestrator.ExpiredSearchIDs(time.Now()) {
+		searchCtx := logging.WithSearchID(ctx, id)
 
-	s.pubSub.Unsubscribe(searchChannelPrefix + string(ID))
+		summary, err := s.end(ctx, entity.NewIDFromHex(id), false)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			s.logger.ErrorContext(searchCtx, "failed to expire search", "error", err)
+			continue
+		}
+
+		s.logger.InfoContext(searchCtx, "search expired", "results", summary.Results)
+
+		if summary.Results == 0 {
+			s.demand.add(summary.Search.Filters)
+		}
+	}
+}
+
+// publishDemand publishes the demand of the searches that expired without
+// finding any trip since it was last published, one event per corridor and
+// time window.
+func (s *Service) publishDemand(ctx context.Context) {
+	for _, d := range s.demand.take() {
+		err := s.demandSub.Publish(ctx, &subscription.Message{Type: EventDemand, Data: d})
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to publish unmet demand", "error", err, "sourceCell", d.SourceCell, "destinationCell", d.DestinationCell)
+
+			// The demand is published along with the next one instead.
+			s.demand.merge(d)
+		}
+	}
+}
+
+// releaseReserve represents how much of the time given to shut down is kept to
+// release the leases, after waiting for the trips being evaluated.
+const releaseReserve = operationTimeout
+
+// Shutdown stops taking over searches and evaluating new trips, waits for the
+// trips that are being evaluated to be published and matched against the saved
+// searches, stops every worker and releases their leases, so that other
+// instances resume the searches right away, and only then closes the searches'
+// subscriptions. It gives up waiting for trips to be published when the context
+// is almost done, to leave time to release the leases.
+func (s *Service) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.shuttingDown, 1)
+
+	s.stopLeases()
+	select {
+	case <-s.leasesDone:
+	case <-ctx.Done():
+	}
+
+	drainCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithDeadline(ctx, deadline.Add(-releaseReserve))
+		defer cancel()
+	}
+
+	s.publishDemand(ctx)
+
+	running := s.orchestrator.SearchIDs()
+
+	drainErr := s.orchestrator.Shutdown(drainCtx)
+	if drainErr != nil {
+		s.logger.WarnContext(ctx, "some results were not published", "error", drainErr)
+	}
+
+	err := s.stopMatchingSaved(drainCtx)
+	if err != nil {
+		s.logger.WarnContext(ctx, "some users of saved searches were not notified", "error", err)
+	}
+
+	// The results already published are still delivered to the callback
+	// URLs, since the instances that resume the searches will not publish
+	// them again.
+	err = s.webhooks.Shutdown(drainCtx)
+	if err != nil {
+		s.logger.WarnContext(ctx, "some results were not delivered to callback URLs", "error", err)
+	}
+
+	err = s.repo.ReleaseLeases(ctx, s.leases.InstanceID)
+
+	// The subscriptions are kept until the searches are released, so that a
+	// search is never left without a channel while this instance holds it.
+	for _, id := range running {
+		s.pubSub.Unsubscribe(searchChannelPrefix + id)
+	}
 
-	err := s.repo.Delete(ID)
 	if err != nil {
 		return err
 	}
 
+	s.logger.InfoContext(ctx, "released searches to be resumed by other instances", "count", len(running))
+
 	return nil
 }
 
+// startWorker creates a subscription for the search and starts its worker on
+// this instance. The results are also delivered to the search's callback URL,
+// if it has one.
+func (s *Service) startWorker(ctx context.Context, search *entity.Search) error {
+	sub, err := s.pubSub.Subscribe(searchChannelPrefix + search.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	if search.CallbackURL != "" {
+		sub = &callbackSubscription{sub, s.webhooks, search}
+	}
+
+	err = s.orchestrator.StartSearch(ctx, search, newResultRecorder(sub, s, search.ID))
+	if err != nil {
+		s.pubSub.Unsubscribe(searchChannelPrefix + search.ID.Hex())
+		return err
+	}
+
+	return nil
+}
+
+// A resultRecorder is a subscription that records the results published to it
+// in the repository, so that they can be counted once the search ends, no
+// matter which instances ran it.
+//
+// Each trip is only recorded once, and none are once MaxResults were, since the
+// same trips are published again when the filters are evaluated again.
+type resultRecorder struct {
+	subscription.Subscription
+	service  *Service
+	searchID entity.ID
+
+	mu       sync.Mutex
+	recorded map[entity.ID]bool
+}
+
+func newResultRecorder(sub subscription.Subscription, service *Service, searchID entity.ID) *resultRecorder {
+	return &resultRecorder{
+		Subscription: sub,
+		service:      service,
+		searchID:     searchID,
+		recorded:     make(map[entity.ID]bool),
+	}
+}
+
+func (r *resultRecorder) Publish(ctx context.Context, msg *subscription.Message) error {
+	err := r.Subscription.Publish(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	t, ok := msg.Data.(*entity.Trip)
+	if msg.Type != EventAddResult || !ok || t == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	if r.recorded[t.ID] || len(r.recorded) >= MaxResults {
+		r.mu.Unlock()
+		return nil
+	}
+	r.recorded[t.ID] = true
+	r.mu.Unlock()
+
+	err = r.service.repo.AddResult(ctx, r.searchID, t.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		r.service.logger.WarnContext(ctx, "failed to record result", "error", err)
+
+		// The trip is recorded again the next time it is found.
+		r.mu.Lock()
+		delete(r.recorded, t.ID)
+		r.mu.Unlock()
+	}
+
+	return nil
+}
+
+// stopWorker stops the search's worker on this instance, if it runs there,
+// and destroys its subscription.
+func (s *Service) stopWorker(id string) {
+	s.orchestrator.StopSearch(id)
+
+	s.pubSub.Unsubscribe(searchChannelPrefix + id)
+}
+
+// tripEventLabel returns the label under which a trip event is counted.
+func tripEventLabel(event string) string {
+	switch event {
+	case trip.EventTripAdded, trip.EventTripChanged:
+		return event
+	default:
+		return metrics.EventOther
+	}
+}
+
 // listenTripsChange is a routine that listens to any update or add of a trip from Ably
 func (s *Service) listenTripsChange(msg *subscription.Message) {
+	if atomic.LoadInt32(&s.shuttingDown) == 1 {
+		return
+	}
+
+	metrics.TripsReceived.WithLabelValues(tripEventLabel(msg.Type)).Inc()
+
+	// Saved searches are only told about new trips, since their users were
+	// already notified of the ones that changed.
+	added := msg.Type == trip.EventTripAdded
+
 	trip := &entity.Trip{}
 	err := json.Unmarshal([]byte(msg.Data.(string)), trip)
 	if err != nil {
-		log.Println("search.Service: unable to unmarshal msg from subscription")
+		s.logger.Error("unable to unmarshal trip from subscription", "error", err)
+		return
+	}
+
+	// The trace started by whoever published the trip is continued, so the
+	// evaluations show up in it.
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(msg.Metadata))
+	ctx, span := tracer.Start(ctx, tripsChannel+" receive", trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+		attribute.String("trip.id", trip.ID.Hex()),
+	))
+	defer span.End()
+
+	ctx = logging.WithTripID(ctx, trip.ID.Hex())
+
+	points, err := s.orchestrator.routePoints(ctx, trip)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to route trip", "error", err)
+		return
+	}
+
+	s.orchestrator.publishRoutedTrip(ctx, trip, points)
+
+	if added {
+		s.matchSaved(ctx, trip, points)
+	}
+}
+
+// matchSaved matches the trip against the saved searches in the background, so
+// that notifying their users does not hold back the trips that follow. It
+// blocks while maxConcurrentSavedMatches trips are being matched, which slows
+// down the trips listener instead of letting the goroutines grow without
+// bounds.
+func (s *Service) matchSaved(ctx context.Context, t *entity.Trip, points []maps.LatLng) {
+	s.matchSlots <- struct{}{}
+
+	s.matchMu.Lock()
+	if s.matchClosed {
+		s.matchMu.Unlock()
+		<-s.matchSlots
 		return
 	}
-	s.orchestrator.PublishTrip(trip)
+	s.matching.Add(1)
+	s.matchMu.Unlock()
 
+	go func() {
+		defer func() {
+			<-s.matchSlots
+			s.matching.Done()
+		}()
+
+		s.saved.Match(ctx, t, points)
+	}()
+}
+
+// stopMatchingSaved stops matching the trips against the saved searches, and
+// waits for the ones being matched, or until the context is done.
+func (s *Service) stopMatchingSaved(ctx context.Context) error {
+	s.matchMu.Lock()
+	s.matchClosed = true
+	s.matchMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.matching.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("search.Service: gave up waiting for saved searches to be matched (%w)", ctx.Err())
+	}
 }
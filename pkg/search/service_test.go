@@ -0,0 +1,288 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/analytics"
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/pubsub/subscription"
+	"azure.com/ecovo/trip-search-service/pkg/trip"
+	"azure.com/ecovo/trip-search-service/pkg/webhook"
+)
+
+// An eventLog records what the fakes of a service were asked to do, in order.
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]string(nil), l.events...)
+}
+
+// A fakePubSub hands out fake subscriptions, and records in its log what is
+// published to them and the topics it unsubscribes from. Publishing waits for
+// the gate to be closed, when there is one.
+type fakePubSub struct {
+	log  *eventLog
+	gate chan struct{}
+}
+
+// A gatedSubscription is a fake subscription handed out by a fakePubSub.
+type gatedSubscription struct {
+	*fakeSubscription
+	pubSub *fakePubSub
+}
+
+func (s *gatedSubscription) Publish(ctx context.Context, msg *subscription.Message) error {
+	if s.pubSub.gate != nil {
+		<-s.pubSub.gate
+	}
+
+	s.pubSub.log.add("publish " + s.topic)
+
+	return s.fakeSubscription.Publish(ctx, msg)
+}
+
+func (p *fakePubSub) Subscribe(topic string) (subscription.Subscription, error) {
+	return &gatedSubscription{newFakeSubscription(topic), p}, nil
+}
+
+func (p *fakePubSub) Unsubscribe(topic string) {
+	p.log.add("unsubscribe " + topic)
+}
+
+// A leaseRepository records the leases released through it, and fails to renew
+// and acquire leases while err is set, like an unreachable database.
+type leaseRepository struct {
+	Repository
+	log *eventLog
+
+	mu  sync.Mutex
+	err error
+}
+
+func (r *leaseRepository) failure() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+func (r *leaseRepository) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.err = err
+}
+
+func (r *leaseRepository) RenewLeases(ctx context.Context, lease *Lease) ([]entity.ID, error) {
+	if err := r.failure(); err != nil {
+		return nil, err
+	}
+
+	return r.Repository.RenewLeases(ctx, lease)
+}
+
+func (r *leaseRepository) AcquireExpiredLease(ctx context.Context, lease *Lease) (*entity.Search, error) {
+	if err := r.failure(); err != nil {
+		return nil, err
+	}
+
+	return r.Repository.AcquireExpiredLease(ctx, lease)
+}
+
+func (r *leaseRepository) ReleaseLeases(ctx context.Context, owner string) error {
+	r.log.add("release")
+
+	return r.Repository.ReleaseLeases(ctx, owner)
+}
+
+// newTestService creates a service for the instance, that shares the searches
+// of the repository with leases that last for the TTL.
+func newTestService(t *testing.T, repo Repository, pubSub *fakePubSub, instanceID string, TTL time.Duration) *Service {
+	savedRepo, err := NewSavedMemoryRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := NewSavedService(savedRepo, &fakeNotifier{}, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analyticsRepo, err := analytics.NewMemoryRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := webhook.NewClient(&webhook.ClientConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := webhook.NewMemoryDeliveryLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhooks, err := webhook.NewDispatcher(client, deliveries, 0, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewService(repo, pubSub, &backfillTripService{}, straightRouteService{}, analytics.NewService(analyticsRepo), saved, webhooks, newTestPool(t), &LeaseConfig{InstanceID: instanceID, TTL: TTL}, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s.(*Service)
+}
+
+// eventually fails the test when the condition is not met within a second.
+func eventually(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// runs returns whether the service runs the search's worker.
+func runs(s *Service, ID entity.ID) bool {
+	for _, id := range s.orchestrator.SearchIDs() {
+		if id == ID.Hex() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A countingRepository counts the results added through it.
+type countingRepository struct {
+	Repository
+	added int
+}
+
+func (r *countingRepository) AddResult(ctx context.Context, ID entity.ID, tripID entity.ID) error {
+	r.added++
+	return r.Repository.AddResult(ctx, ID, tripID)
+}
+
+func TestResultRecorder(t *testing.T) {
+	t.Run("Should record each trip once", func(t *testing.T) {
+		ctx := context.Background()
+
+		memory, _ := NewMemoryRepository()
+		repo := &countingRepository{Repository: memory}
+		ID, err := repo.Create(ctx, &entity.Search{Filters: &entity.Filters{}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sub := newFakeSubscription("search:" + ID.Hex())
+		r := newResultRecorder(sub, &Service{repo: repo, logger: logging.Discard()}, ID)
+
+		for _, tripID := range []entity.ID{"trip1", "trip2", "trip1"} {
+			err := r.Publish(ctx, &subscription.Message{Type: EventAddResult, Data: &entity.Trip{ID: tripID}})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if sub.count() != 3 {
+			t.Errorf("expected every result to be published, got %d messages", sub.count())
+		}
+
+		if repo.added != 2 {
+			t.Errorf("expected 2 results to be recorded, got %d", repo.added)
+		}
+	})
+}
+
+func TestServiceShutdown(t *testing.T) {
+	t.Run("Should drain the trips being evaluated, then release the leases, then close the subscriptions", func(t *testing.T) {
+		ctx := context.Background()
+		log := &eventLog{}
+		pubSub := &fakePubSub{log: log, gate: make(chan struct{})}
+
+		memory, err := NewMemoryRepository()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := newTestService(t, &leaseRepository{Repository: memory, log: log}, pubSub, "instance", time.Minute)
+
+		search := newTestSearch("", montreal, quebec)
+		search.Filters.LeaveAt = time.Now().Add(time.Hour)
+
+		search, err = s.Create(ctx, search)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tripAdded := func(id string) *subscription.Message {
+			data, err := json.Marshal(newTestTrip(id, montreal, quebec))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return &subscription.Message{Type: trip.EventTripAdded, Data: string(data)}
+		}
+
+		// The trip is evaluated, but its result is held back by the gate.
+		s.listenTripsChange(tripAdded("before"))
+
+		shutdownCtx, cancel := context.WithTimeout(ctx, releaseReserve+5*time.Second)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- s.Shutdown(shutdownCtx)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+
+		// The trips received once the shutdown started are not evaluated.
+		s.listenTripsChange(tripAdded("after"))
+
+		if events := log.list(); len(events) != 0 {
+			t.Errorf("expected the shutdown to wait for the trip being evaluated, got %v", events)
+		}
+
+		close(pubSub.gate)
+
+		err = <-done
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		topic := searchChannelPrefix + search.ID.Hex()
+		expected := []string{"publish " + topic, "release", "unsubscribe " + topic}
+		if events := log.list(); !reflect.DeepEqual(events, expected) {
+			t.Errorf("expected %v, got %v", expected, events)
+		}
+	})
+}
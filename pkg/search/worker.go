@@ -1,31 +1,51 @@
 package search
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"azure.com/ecovo/trip-search-service/pkg/metrics"
 	"azure.com/ecovo/trip-search-service/pkg/pubsub/subscription"
-	"azure.com/ecovo/trip-search-service/pkg/route"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
 	"github.com/umahmood/haversine"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"googlemaps.github.io/maps"
 )
 
 // A Worker does all the heavy lifting to search for trips that either match
-// the search filters or come close. It runs in a Go routine, to avoid blocking
-// the entire service, and publishes results to a subscription.
+// the search filters or come close, and publishes results to a subscription.
+//
+// It does not own a goroutine. The trips it must evaluate are submitted to a
+// pool, which evaluates them as goroutines become available, so that idle
+// searches cost next to nothing.
 type Worker struct {
-	filters      *entity.Filters
-	sub          subscription.Subscription
-	started      bool
-	trips        chan *entity.Trip
-	routeService route.UseCase
-	quit         chan bool
+	filters  *entity.Filters
+	sub      subscription.Subscription
+	started  bool
+	backfill func(ctx context.Context) error
+	logger   *slog.Logger
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// A routedTrip is a trip along with the points of the route it follows.
+type routedTrip struct {
+	trip   *entity.Trip
+	points []maps.LatLng
 }
 
 // NewWorker creates a new search worker that uses the subscription to publish
 // results.
-func NewWorker(filters *entity.Filters, sub subscription.Subscription, routeService route.UseCase) (*Worker, error) {
+//
+// The backfill function is optional. When it is given, it is run in the
+// background as soon as the worker starts, to retrieve the trips that already
+// exist.
+func NewWorker(filters *entity.Filters, sub subscription.Subscription, backfill func(ctx context.Context) error, logger *slog.Logger) (*Worker, error) {
 	if filters == nil {
 		return nil, fmt.Errorf("search.Worker: cannot work with nil filters")
 	}
@@ -34,82 +54,129 @@ func NewWorker(filters *entity.Filters, sub subscription.Subscription, routeServ
 		return nil, fmt.Errorf("search.Worker: cannot work with nil subscription")
 	}
 
+	if logger == nil {
+		return nil, fmt.Errorf("search.Worker: cannot work with nil logger")
+	}
+
 	return &Worker{
-		filters:      filters,
-		sub:          sub,
-		trips:        make(chan *entity.Trip),
-		routeService: routeService,
-		quit:         make(chan bool),
+		filters:  filters,
+		sub:      sub,
+		backfill: backfill,
+		logger:   logger,
 	}, nil
 }
 
-// Start tells the worker to start searching for trips.
-func (w *Worker) Start() {
+// Start tells the worker to start searching for trips, until it is stopped or
+// the given context is done. The entries it logs are tagged with the IDs found
+// in the context.
+func (w *Worker) Start(ctx context.Context) {
 	if w.started {
 		return
 	}
 
 	w.started = true
 
-	go w.run()
+	w.ctx, w.cancel = context.WithCancel(ctx)
+
+	if w.backfill != nil {
+		go w.runBackfill()
+	}
 }
 
-// Stop tells the worker to stop searching for trips.
+// Stop tells the worker to stop searching for trips. Trips that were already
+// submitted to the pool are dropped.
 func (w *Worker) Stop() {
 	if !w.started {
 		return
 	}
 
-	w.quit <- true
+	w.cancel()
 
 	w.started = false
 }
 
-func (w *Worker) run() {
-	for {
-		select {
-		case <-w.quit:
-			return
-		case trip := <-w.trips:
-			r, err := w.routeService.GetRoute(trip)
-			if err != nil {
-				log.Println("searchWorker: failed to get route from google maps")
-				break
-			}
-
-			isValid := false
-			if w.filters != nil && trip != nil {
-				isValid, err = validateTrip(trip, w.filters, r)
-				if err != nil {
-					log.Println(err)
-					break
-				}
-			}
-
-			if isValid {
-				err := w.sub.Publish(&subscription.Message{
-					Type: EventAddResult,
-					Data: trip,
-				})
-				if err != nil {
-					log.Println(err)
-					break
-				}
-			}
-		default:
-			break
-		}
+func (w *Worker) runBackfill() {
+	err := w.backfill(w.ctx)
+	if err != nil && w.ctx.Err() == nil {
+		w.logger.WarnContext(w.ctx, "backfill failed, only live updates will be received", "error", err)
 	}
 }
 
-// validateTrip will validate
-func validateTrip(t *entity.Trip, f *entity.Filters, route maps.Route) (bool, error) {
-	threshold := metersToKM(float64(*f.RadiusThresh))
-	points, err := route.OverviewPolyline.Decode()
+// evaluate publishes the trip if it matches the search filters, unless the
+// worker has stopped. Its span is a child of the given one, which belongs to
+// whatever submitted the trip.
+func (w *Worker) evaluate(parent trace.SpanContext, t routedTrip) {
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	if w.filters == nil || t.trip == nil {
+		return
+	}
+
+	ctx := logging.WithTripID(w.ctx, t.trip.ID.Hex())
+	ctx = trace.ContextWithSpanContext(ctx, parent)
+
+	ctx, span := tracer.Start(ctx, "search.Worker.evaluate", trace.WithAttributes(
+		attribute.String("trip.id", t.trip.ID.Hex()),
+	))
+
+	reason := rejectReason(t.trip, w.filters, t.points)
+	span.SetAttributes(attribute.String("search.reason", reason))
+	if reason != metrics.ReasonNone {
+		metrics.TripEvaluations.WithLabelValues("rejected", reason).Inc()
+		w.logger.DebugContext(ctx, "trip rejected", "reason", reason)
+		tracing.End(span, nil)
+		return
+	}
+
+	metrics.TripEvaluations.WithLabelValues("matched", reason).Inc()
+
+	err := w.sub.Publish(ctx, &subscription.Message{
+		Type: EventAddResult,
+		Data: t.trip,
+	})
+	tracing.End(span, err)
 	if err != nil {
-		return false, err
+		w.logger.ErrorContext(ctx, "failed to publish result", "error", err)
+		return
 	}
 
+	w.logger.DebugContext(ctx, "trip matched")
+}
+
+// validateTrip returns whether the trip matches the search filters.
+func validateTrip(t *entity.Trip, f *entity.Filters, points []maps.LatLng) bool {
+	return rejectReason(t, f, points) == metrics.ReasonNone
+}
+
+// occurrenceTolerance represents how far from the nearest occurrence of a
+// recurring search a trip can leave and still match it.
+const occurrenceTolerance = time.Hour
+
+// rejectReason returns why the trip does not match the search filters, or
+// metrics.ReasonNone when it does.
+//
+// A trip matches a recurring search when it leaves within occurrenceTolerance
+// of the nearest occurrence. The trips that only tell when they arrive are not
+// checked against the occurrences.
+//
+// A trip matches a search with a time window when it leaves and arrives within
+// its bounds. The times the trip does not tell are not checked.
+func rejectReason(t *entity.Trip, f *entity.Filters, points []maps.LatLng) string {
+	if f.Recurrence != nil && !t.LeaveAt.IsZero() {
+		nearest, ok := f.Recurrence.Nearest(t.LeaveAt)
+		if !ok || absDuration(t.LeaveAt.Sub(nearest)) > occurrenceTolerance {
+			return metrics.ReasonNoOccurrence
+		}
+	}
+
+	if f.HasWindow() && (outside(t.LeaveAt, f.LeaveAfter, f.LeaveBefore) || outside(t.ArriveBy, f.ArriveAfter, f.ArriveBefore)) {
+		return metrics.ReasonOutsideWindow
+	}
+
+	threshold := metersToKM(float64(f.Radius()))
+
 	source := haversine.Coord{Lat: f.Source.Latitude, Lon: f.Source.Longitude}
 	destination := haversine.Coord{Lat: f.Destination.Latitude, Lon: f.Destination.Longitude}
 
@@ -130,14 +197,37 @@ func validateTrip(t *entity.Trip, f *entity.Filters, route maps.Route) (bool, er
 		}
 	}
 
-	if isSourceOk && isDestinationOk {
-		return true, nil
+	if !isSourceOk {
+		return metrics.ReasonSourceTooFar
 	}
 
-	return false, nil
+	if !isDestinationOk {
+		return metrics.ReasonDestinationTooFar
+	}
+
+	return metrics.ReasonNone
+}
+
+// outside returns whether the time is known and outside of the bounds. Both
+// bounds are optional and included.
+func outside(at time.Time, after time.Time, before time.Time) bool {
+	if at.IsZero() {
+		return false
+	}
+
+	return (!after.IsZero() && at.Before(after)) || (!before.IsZero() && at.After(before))
 }
 
 // metersToKM converts meters into kilometers
 func metersToKM(meters float64) float64 {
 	return meters / 1000.0
 }
+
+// absDuration returns the absolute value of the duration.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
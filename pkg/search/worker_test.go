@@ -0,0 +1,94 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/metrics"
+	"googlemaps.github.io/maps"
+)
+
+func TestRejectReason(t *testing.T) {
+	route := []maps.LatLng{
+		{Lat: montreal.Latitude, Lng: montreal.Longitude},
+		{Lat: drummondville.Latitude, Lng: drummondville.Longitude},
+	}
+
+	t.Run("Should accept a trip that passes by the source and the destination", func(t *testing.T) {
+		f := &entity.Filters{Source: montreal, Destination: drummondville}
+
+		reason := rejectReason(nil, f, route)
+		if reason != metrics.ReasonNone {
+			t.Errorf("expected reason %s, got %s", metrics.ReasonNone, reason)
+		}
+	})
+
+	t.Run("Should reject a trip that does not pass by the source", func(t *testing.T) {
+		f := &entity.Filters{Source: quebec, Destination: drummondville}
+
+		reason := rejectReason(nil, f, route)
+		if reason != metrics.ReasonSourceTooFar {
+			t.Errorf("expected reason %s, got %s", metrics.ReasonSourceTooFar, reason)
+		}
+	})
+
+	t.Run("Should reject a trip that does not pass by the destination", func(t *testing.T) {
+		f := &entity.Filters{Source: montreal, Destination: quebec}
+
+		reason := rejectReason(nil, f, route)
+		if reason != metrics.ReasonDestinationTooFar {
+			t.Errorf("expected reason %s, got %s", metrics.ReasonDestinationTooFar, reason)
+		}
+	})
+
+	t.Run("Should match a recurring search only close to one of its occurrences", func(t *testing.T) {
+		f := &entity.Filters{Source: montreal, Destination: drummondville, Recurrence: &entity.Recurrence{
+			Days:      []string{"monday", "wednesday"},
+			TimeOfDay: "07:30",
+			StartDate: "2026-10-19",
+			EndDate:   "2026-10-30",
+		}}
+
+		for _, c := range []struct {
+			leaveAt  time.Time
+			expected string
+		}{
+			{time.Date(2026, time.October, 21, 8, 0, 0, 0, time.UTC), metrics.ReasonNone},
+			{time.Date(2026, time.October, 21, 9, 0, 0, 0, time.UTC), metrics.ReasonNoOccurrence},
+			{time.Date(2026, time.October, 22, 7, 30, 0, 0, time.UTC), metrics.ReasonNoOccurrence},
+			{time.Date(2026, time.November, 2, 7, 30, 0, 0, time.UTC), metrics.ReasonNoOccurrence},
+		} {
+			reason := rejectReason(&entity.Trip{LeaveAt: c.leaveAt}, f, route)
+			if reason != c.expected {
+				t.Errorf("expected reason %s for a trip leaving at %s, got %s", c.expected, c.leaveAt, reason)
+			}
+		}
+	})
+
+	t.Run("Should match a search with a time window only within its bounds", func(t *testing.T) {
+		f := &entity.Filters{
+			Source:       montreal,
+			Destination:  drummondville,
+			LeaveAfter:   time.Date(2026, time.October, 23, 7, 0, 0, 0, time.UTC),
+			LeaveBefore:  time.Date(2026, time.October, 23, 9, 0, 0, 0, time.UTC),
+			ArriveBefore: time.Date(2026, time.October, 23, 10, 0, 0, 0, time.UTC),
+		}
+
+		for _, c := range []struct {
+			trip     *entity.Trip
+			expected string
+		}{
+			{&entity.Trip{LeaveAt: f.LeaveAfter, ArriveBy: f.LeaveBefore}, metrics.ReasonNone},
+			{&entity.Trip{LeaveAt: f.LeaveBefore}, metrics.ReasonNone},
+			{&entity.Trip{ArriveBy: f.ArriveBefore}, metrics.ReasonNone},
+			{&entity.Trip{LeaveAt: f.LeaveAfter.Add(-time.Minute)}, metrics.ReasonOutsideWindow},
+			{&entity.Trip{LeaveAt: f.LeaveBefore, ArriveBy: f.ArriveBefore.Add(time.Minute)}, metrics.ReasonOutsideWindow},
+		} {
+			reason := rejectReason(c.trip, f, route)
+			if reason != c.expected {
+				t.Errorf("expected reason %s for a trip leaving at %s and arriving by %s, got %s", c.expected, c.trip.LeaveAt, c.trip.ArriveBy, reason)
+			}
+		}
+	})
+}
@@ -0,0 +1,125 @@
+// Package tracing sets up OpenTelemetry tracing for the service.
+//
+// Packages create their spans with a tracer obtained from otel.Tracer, which
+// does nothing until a provider is created with NewProvider. The W3C trace
+// context is used to propagate traces to and from other services.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The exporters spans can be sent to.
+const (
+	// ExporterNone drops the spans. The trace context is still propagated.
+	ExporterNone = "none"
+
+	// ExporterStdout writes the spans to the standard output, which is
+	// useful locally.
+	ExporterStdout = "stdout"
+
+	// ExporterOTLP sends the spans to an OpenTelemetry collector over HTTP.
+	// The collector is configured with the standard OTEL_EXPORTER_OTLP_*
+	// environment variables.
+	ExporterOTLP = "otlp"
+)
+
+// DefaultServiceName represents the name the service is known as in traces,
+// unless OTEL_SERVICE_NAME is defined.
+const DefaultServiceName = "trip-search-service"
+
+// Config contains the information required to set up tracing.
+type Config struct {
+	// Exporter specifies where spans are sent, either ExporterNone,
+	// ExporterStdout or ExporterOTLP.
+	//
+	// An empty exporter means ExporterNone.
+	Exporter string
+}
+
+// A Provider creates the spans of the service and sends them to an exporter.
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// NewProvider creates a provider that sends spans to the configured exporter,
+// and makes it the global provider used by otel.Tracer.
+func NewProvider(conf *Config) (*Provider, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("tracing: missing configuration")
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch conf.Exporter {
+	case "", ExporterNone:
+		return &Provider{}, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(context.Background())
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter \"%s\"", conf.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: failed to create exporter (%s)", err)
+	}
+
+	res := resource.Default()
+	if os.Getenv("OTEL_SERVICE_NAME") == "" {
+		res, err = resource.Merge(res, resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(DefaultServiceName)))
+		if err != nil {
+			return nil, fmt.Errorf("tracing: failed to create resource (%s)", err)
+		}
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+
+	return &Provider{tp}, nil
+}
+
+// Shutdown sends the spans that were not exported yet and stops the exporter.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.tp == nil {
+		return nil
+	}
+
+	err := p.tp.Shutdown(ctx)
+	if err != nil {
+		return fmt.Errorf("tracing: failed to shut down provider (%s)", err)
+	}
+
+	return nil
+}
+
+// End records the error on the span, if there is one, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
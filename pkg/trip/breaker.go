@@ -0,0 +1,87 @@
+package trip
+
+import (
+	"sync"
+	"time"
+)
+
+// A circuitBreaker keeps track of consecutive failures of calls to a
+// dependency and stops letting calls through for a while once too many have
+// failed, to give the dependency a chance to recover.
+//
+// Once the cooldown has elapsed, a single trial call is let through. If it
+// succeeds, the breaker closes again. Otherwise, it stays open for another
+// cooldown.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns whether a call can be made.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+
+	if b.trial || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+
+	b.trial = true
+
+	return true
+}
+
+// isOpen returns whether calls are suspended, without letting a trial call
+// through.
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.threshold > 0 && b.failures >= b.threshold && (b.trial || time.Since(b.openedAt) < b.cooldown)
+}
+
+// success records that a call succeeded, closing the breaker.
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+}
+
+// failure records that a call failed, opening the breaker if too many calls
+// have failed in a row.
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// abandon records that a call was given up on before its outcome was known,
+// like when its context is cancelled. A trial call can be let through again
+// right away.
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
@@ -0,0 +1,281 @@
+package trip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials is an interface representing the ability to authenticate an
+// outgoing request to the trip service.
+type Credentials interface {
+	// Apply adds the credentials to the request's headers.
+	Apply(req *http.Request) error
+}
+
+// BasicCredentials are credentials that authenticate requests with a static
+// token using the basic authentication scheme.
+type BasicCredentials struct {
+	token string
+}
+
+// NewBasicCredentials creates credentials that authenticate requests with the
+// given static token.
+func NewBasicCredentials(token string) (Credentials, error) {
+	if token == "" {
+		return nil, fmt.Errorf("trip.BasicCredentials: token is empty")
+	}
+
+	return &BasicCredentials{token}, nil
+}
+
+// Apply adds the static token to the request's authorization header.
+func (c *BasicCredentials) Apply(req *http.Request) error {
+	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", c.token))
+
+	return nil
+}
+
+// ClientCredentialsConfig contains the information required to obtain access
+// tokens using the OAuth2 client credentials grant.
+type ClientCredentialsConfig struct {
+	// TokenURL represents the URL of the authorization server's token
+	// endpoint.
+	TokenURL string
+
+	// ClientID represents the identifier of the service's client.
+	ClientID string
+
+	// ClientSecret represents the secret of the service's client.
+	ClientSecret string
+
+	// Audience represents the API for which the access token is requested.
+	// It is optional.
+	Audience string
+
+	// Scopes represents the scopes requested for the access token. They are
+	// optional.
+	Scopes []string
+}
+
+// Validate looks at the configuration's contents to ensure it has all the
+// required fields.
+func (conf *ClientCredentialsConfig) validate() error {
+	if conf.TokenURL == "" {
+		return errors.New("missing token URL")
+	}
+
+	if conf.ClientID == "" {
+		return errors.New("missing client ID")
+	}
+
+	if conf.ClientSecret == "" {
+		return errors.New("missing client secret")
+	}
+
+	return nil
+}
+
+// ClientCredentials are credentials that authenticate requests with an access
+// token obtained using the OAuth2 client credentials grant.
+//
+// The access token is cached and only refreshed when it is about to expire.
+// A single token request is made at a time, and the requests waiting for it
+// give up when their context is done.
+type ClientCredentials struct {
+	conf   *ClientCredentialsConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	refresh   *tokenRefresh
+}
+
+// A tokenRefresh is a token request in flight. Its done channel is closed once
+// the token or the error is set.
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// DefaultTokenTimeout represents how long a token request can take when the
+// credentials are not given an HTTP client.
+const DefaultTokenTimeout = 10 * time.Second
+
+// clientCredentialsExpiryLeeway represents how long before its expiry an
+// access token is considered expired, to avoid using a token that expires
+// while a request is in flight. Short-lived tokens are only given a quarter of
+// their lifetime as leeway, so that they are still cached.
+const clientCredentialsExpiryLeeway = time.Minute
+
+// expiryLeeway returns how long before it expires a token that lives for the
+// given time is considered expired.
+func expiryLeeway(lifetime time.Duration) time.Duration {
+	if lifetime/4 < clientCredentialsExpiryLeeway {
+		return lifetime / 4
+	}
+
+	return clientCredentialsExpiryLeeway
+}
+
+// NewClientCredentials creates credentials that obtain access tokens from the
+// token endpoint specified in the given configuration. The client's timeout
+// bounds the token requests, since they are not tied to the context of any
+// request.
+func NewClientCredentials(conf *ClientCredentialsConfig, client *http.Client) (Credentials, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("trip.ClientCredentials: missing configuration")
+	}
+
+	err := conf.validate()
+	if err != nil {
+		return nil, fmt.Errorf("trip.ClientCredentials: configuration %s", err)
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTokenTimeout}
+	}
+
+	return &ClientCredentials{conf: conf, client: client}, nil
+}
+
+// Apply adds a valid access token to the request's authorization header,
+// requesting a new one if the cached token is missing or expired.
+func (c *ClientCredentials) Apply(req *http.Request) error {
+	token, err := c.accessToken(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	return nil
+}
+
+func (c *ClientCredentials) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	if c.token != "" && time.Now().Before(c.expiresAt) {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+
+	r := c.refresh
+	if r == nil {
+		r = &tokenRefresh{done: make(chan struct{})}
+		c.refresh = r
+		go c.refreshToken(r)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.token, r.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("trip.ClientCredentials: gave up waiting for a token (%s)", ctx.Err())
+	}
+}
+
+// refreshToken requests a new token without holding the lock, caches it, and
+// hands it to the requests waiting for it.
+func (c *ClientCredentials) refreshToken(r *tokenRefresh) {
+	token, lifetime, err := c.requestToken()
+
+	c.mu.Lock()
+	if err == nil {
+		c.token = token
+		c.expiresAt = time.Now().Add(lifetime - expiryLeeway(lifetime))
+	}
+	c.refresh = nil
+	c.mu.Unlock()
+
+	r.token, r.err = token, err
+	close(r.done)
+}
+
+// requestToken requests an access token from the token endpoint, and returns
+// it along with how long it lives.
+func (c *ClientCredentials) requestToken() (string, time.Duration, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.conf.ClientID)
+	form.Set("client_secret", c.conf.ClientSecret)
+	if c.conf.Audience != "" {
+		form.Set("audience", c.conf.Audience)
+	}
+	if len(c.conf.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.conf.Scopes, " "))
+	}
+
+	req, err := http.NewRequest("POST", c.conf.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("trip.ClientCredentials: failed to create token request (%s)", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("trip.ClientCredentials: failed to request token (%s)", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("trip.ClientCredentials: failed to request token (HTTP %d)", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", 0, fmt.Errorf("trip.ClientCredentials: failed to decode token (%s)", err)
+	}
+
+	if body.AccessToken == "" {
+		return "", 0, fmt.Errorf("trip.ClientCredentials: token response is missing an access token")
+	}
+
+	return body.AccessToken, time.Duration(body.ExpiresIn) * time.Second, nil
+}
+
+// A TokenSource is a function that extracts an end user's bearer token from a
+// request's context.
+type TokenSource func(ctx context.Context) (string, error)
+
+// ForwardedCredentials are credentials that authenticate requests on behalf of
+// the end user, by forwarding the bearer token found in the request's context.
+type ForwardedCredentials struct {
+	source TokenSource
+}
+
+// NewForwardedCredentials creates credentials that forward the bearer token
+// extracted from the request's context by the given token source.
+func NewForwardedCredentials(source TokenSource) (Credentials, error) {
+	if source == nil {
+		return nil, fmt.Errorf("trip.ForwardedCredentials: token source is nil")
+	}
+
+	return &ForwardedCredentials{source}, nil
+}
+
+// Apply adds the end user's bearer token to the request's authorization
+// header.
+func (c *ForwardedCredentials) Apply(req *http.Request) error {
+	token, err := c.source(req.Context())
+	if err != nil {
+		return fmt.Errorf("trip.ForwardedCredentials: no token to forward (%s)", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	return nil
+}
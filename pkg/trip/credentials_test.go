@@ -0,0 +1,134 @@
+package trip
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientCredentials(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "id" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, requests)
+	}))
+	defer server.Close()
+
+	credentials, err := NewClientCredentials(&ClientCredentialsConfig{
+		TokenURL:     server.URL,
+		ClientID:     "id",
+		ClientSecret: "secret"}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Should cache the access token until it expires", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest("GET", "/trips", nil)
+
+			err := credentials.Apply(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if req.Header.Get("Authorization") != "Bearer token-1" {
+				t.Errorf("unexpected authorization header \"%s\"", req.Header.Get("Authorization"))
+			}
+		}
+
+		if requests != 1 {
+			t.Errorf("expected 1 token request, got %d", requests)
+		}
+	})
+}
+
+func TestClientCredentialsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	credentials, err := NewClientCredentials(&ClientCredentialsConfig{
+		TokenURL:     server.URL,
+		ClientID:     "id",
+		ClientSecret: "secret"}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Should stop waiting for a token when the request is done", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			req := httptest.NewRequest("GET", "/trips", nil).WithContext(ctx)
+
+			err := credentials.Apply(req)
+			cancel()
+			if err == nil {
+				t.Error("expected an error while the token endpoint hangs")
+			}
+		}
+	})
+}
+
+func TestExpiryLeeway(t *testing.T) {
+	t.Run("Should give short-lived tokens a fraction of their lifetime as leeway", func(t *testing.T) {
+		for lifetime, expected := range map[time.Duration]time.Duration{
+			time.Hour:        clientCredentialsExpiryLeeway,
+			30 * time.Second: 7500 * time.Millisecond,
+			0:                0,
+		} {
+			leeway := expiryLeeway(lifetime)
+			if leeway != expected {
+				t.Errorf("expected a leeway of %s for a lifetime of %s, got %s", expected, lifetime, leeway)
+			}
+		}
+	})
+}
+
+func TestForwardedCredentials(t *testing.T) {
+	type key struct{}
+
+	credentials, err := NewForwardedCredentials(func(ctx context.Context) (string, error) {
+		token, ok := ctx.Value(key{}).(string)
+		if !ok {
+			return "", fmt.Errorf("no token")
+		}
+		return token, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Should forward the token found in the request's context", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/trips", nil)
+		req = req.WithContext(context.WithValue(req.Context(), key{}, "user-token"))
+
+		err := credentials.Apply(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if req.Header.Get("Authorization") != "Bearer user-token" {
+			t.Errorf("unexpected authorization header \"%s\"", req.Header.Get("Authorization"))
+		}
+	})
+
+	t.Run("Should fail when no token is found in the request's context", func(t *testing.T) {
+		err := credentials.Apply(httptest.NewRequest("GET", "/trips", nil))
+		if err == nil {
+			t.Fail()
+		}
+	})
+}
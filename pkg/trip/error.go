@@ -8,3 +8,14 @@ type UnauthorizedError struct {
 func (e UnauthorizedError) Error() string {
 	return e.msg
 }
+
+// An UnavailableError is an error that represents that the trip service could
+// not be reached, either because it kept failing or because calls to it are
+// temporarily suspended to let it recover.
+type UnavailableError struct {
+	msg string
+}
+
+func (e UnavailableError) Error() string {
+	return e.msg
+}
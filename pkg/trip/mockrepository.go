@@ -1,6 +1,7 @@
 package trip
 
 import (
+	"context"
 	"time"
 
 	"azure.com/ecovo/trip-search-service/pkg/entity"
@@ -10,22 +11,30 @@ import (
 type MockRepository struct {
 }
 
+// mockTripsCount represents how many mocked trips are returned in total.
+const mockTripsCount = 10
+
 // NewMockRepository creates a mock trip repository.
 func NewMockRepository() (Repository, error) {
 	return &MockRepository{}, nil
 }
 
-// Find retrieves all trips based on given filters.
-func (r *MockRepository) Find(f *entity.Filters) ([]*entity.Trip, error) {
+// Find retrieves a page of trips based on given filters.
+func (r *MockRepository) Find(ctx context.Context, f *entity.Filters, page Page) ([]*entity.Trip, error) {
 	var trips []*entity.Trip
 
-	for i := 0; i < 10; i++ {
+	for i := page.Offset; i < mockTripsCount && i < page.Offset+page.Limit; i++ {
 		trips = append(trips, createMockTrip())
 	}
 
 	return trips, nil
 }
 
+// Ping always succeeds, since mocked trips are always available.
+func (r *MockRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
 // createMockTrip creates a mocked trip
 func createMockTrip() *entity.Trip {
 	return &entity.Trip{
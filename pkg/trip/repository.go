@@ -1,11 +1,25 @@
 package trip
 
 import (
+	"context"
+
 	"azure.com/ecovo/trip-search-service/pkg/entity"
 )
 
+// A Page represents a window of results to retrieve.
+type Page struct {
+	// Offset represents how many results to skip.
+	Offset int
+
+	// Limit represents the maximum number of results to retrieve.
+	Limit int
+}
+
 // Repository is an interface representing the ability to perform CRUD
 // operations on trips in a database.
 type Repository interface {
-	Find(filters *entity.Filters) ([]*entity.Trip, error)
+	Find(ctx context.Context, filters *entity.Filters, page Page) ([]*entity.Trip, error)
+
+	// Ping checks that the trips can be reached.
+	Ping(ctx context.Context) error
 }
@@ -1,19 +1,38 @@
 package trip
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	"strconv"
 	"time"
 
 	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/metrics"
+	"azure.com/ecovo/trip-search-service/pkg/requestid"
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
 	"github.com/mongodb/mongo-go-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("azure.com/ecovo/trip-search-service/pkg/trip")
+
 // A RestRepository is a repository that performs HTTP requests on trips from the trip-service.
 type RestRepository struct {
-	domain    string
-	authToken string
+	domain      string
+	credentials Credentials
+	conf        *ClientConfig
+	client      *http.Client
+	breaker     *circuitBreaker
+	logger      *slog.Logger
 }
 
 type document struct {
@@ -35,64 +54,319 @@ const (
 
 	// ArriveByString is a string used for query params
 	ArriveByString = "arriveBy"
+
+	// LeaveAfterString is a string used for query params
+	LeaveAfterString = "leaveAfter"
+
+	// LeaveBeforeString is a string used for query params
+	LeaveBeforeString = "leaveBefore"
+
+	// ArriveAfterString is a string used for query params
+	ArriveAfterString = "arriveAfter"
+
+	// ArriveBeforeString is a string used for query params
+	ArriveBeforeString = "arriveBefore"
+
+	// OffsetString is a string used for query params
+	OffsetString = "offset"
+
+	// LimitString is a string used for query params
+	LimitString = "limit"
+)
+
+// ClientConfig contains the information required to configure how requests
+// are made to the trip service.
+type ClientConfig struct {
+	// Timeout specifies how long to wait for a single request to the trip
+	// service to complete.
+	//
+	// A timeout of zero means DefaultTimeout.
+	Timeout time.Duration
+
+	// MaxRetries specifies how many times a failed request is retried before
+	// giving up. Only network errors and server errors are retried.
+	MaxRetries int
+
+	// RetryBaseDelay specifies how long to wait before the first retry. The
+	// delay doubles for each subsequent retry, and a random jitter is applied
+	// to it.
+	//
+	// A delay of zero means DefaultRetryBaseDelay.
+	RetryBaseDelay time.Duration
+
+	// RetryMaxDelay specifies the longest delay to wait between two retries.
+	//
+	// A delay of zero means DefaultRetryMaxDelay.
+	RetryMaxDelay time.Duration
+
+	// BreakerThreshold specifies how many requests must fail in a row for
+	// requests to the trip service to be suspended.
+	//
+	// A threshold of zero disables the circuit breaker.
+	BreakerThreshold int
+
+	// BreakerCooldown specifies how long requests to the trip service are
+	// suspended once the breaker threshold is reached.
+	//
+	// A cooldown of zero means DefaultBreakerCooldown.
+	BreakerCooldown time.Duration
+
+	// Transport specifies the mechanism by which requests are made. It is
+	// optional and defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+const (
+	// DefaultTimeout represents the default amount of time to wait for a
+	// single request to the trip service to complete.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxRetries represents the default number of times a failed
+	// request is retried.
+	DefaultMaxRetries = 2
+
+	// DefaultRetryBaseDelay represents the default amount of time to wait
+	// before the first retry.
+	DefaultRetryBaseDelay = 200 * time.Millisecond
+
+	// DefaultRetryMaxDelay represents the default longest amount of time to
+	// wait between two retries.
+	DefaultRetryMaxDelay = 2 * time.Second
+
+	// DefaultBreakerThreshold represents the default number of requests that
+	// must fail in a row for requests to be suspended.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerCooldown represents the default amount of time during
+	// which requests are suspended.
+	DefaultBreakerCooldown = 30 * time.Second
 )
 
-// NewRestRepository creates a trip repository for a MongoDB collection.
-func NewRestRepository(domain string, authToken string) (Repository, error) {
+// DefaultClientConfig returns the configuration used when none is given to
+// NewRestRepository.
+func DefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		Timeout:          DefaultTimeout,
+		MaxRetries:       DefaultMaxRetries,
+		RetryBaseDelay:   DefaultRetryBaseDelay,
+		RetryMaxDelay:    DefaultRetryMaxDelay,
+		BreakerThreshold: DefaultBreakerThreshold,
+		BreakerCooldown:  DefaultBreakerCooldown,
+	}
+}
+
+// NewRestRepository creates a trip repository that makes requests to the trip
+// service hosted on the given domain.
+func NewRestRepository(domain string, credentials Credentials, conf *ClientConfig, logger *slog.Logger) (Repository, error) {
 	if domain == "" {
 		return nil, fmt.Errorf("trip.Rest Repository: domain is nil")
 	}
 
-	if authToken == "" {
-		return nil, fmt.Errorf("trip.Rest Repository: authToken is nil")
+	if credentials == nil {
+		return nil, fmt.Errorf("trip.Rest Repository: credentials are nil")
+	}
+
+	if logger == nil {
+		return nil, fmt.Errorf("trip.Rest Repository: logger is nil")
+	}
+
+	if conf == nil {
+		conf = DefaultClientConfig()
+	}
+
+	if conf.MaxRetries < 0 {
+		return nil, fmt.Errorf("trip.Rest Repository: max retries cannot be negative")
+	}
+
+	c := *conf
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.RetryBaseDelay == 0 {
+		c.RetryBaseDelay = DefaultRetryBaseDelay
+	}
+	if c.RetryMaxDelay == 0 {
+		c.RetryMaxDelay = DefaultRetryMaxDelay
+	}
+	if c.BreakerCooldown == 0 {
+		c.BreakerCooldown = DefaultBreakerCooldown
 	}
 
-	return &RestRepository{domain, authToken}, nil
+	return &RestRepository{
+		domain:      domain,
+		credentials: credentials,
+		conf:        &c,
+		client:      &http.Client{Timeout: c.Timeout, Transport: c.Transport},
+		breaker:     newCircuitBreaker(c.BreakerThreshold, c.BreakerCooldown),
+		logger:      logger,
+	}, nil
 }
 
-// Find retrieves all trips based on given filters.
-func (r *RestRepository) Find(f *entity.Filters) ([]*entity.Trip, error) {
-	req, err := http.NewRequest("GET", "https://"+r.domain+"/trips", nil)
+// Find retrieves a page of trips based on given filters.
+//
+// Failed requests are retried with a jittered exponential backoff. When the
+// trip service keeps failing, requests are suspended for a while and an
+// UnavailableError is returned right away.
+func (r *RestRepository) Find(ctx context.Context, f *entity.Filters, page Page) ([]*entity.Trip, error) {
+	params, err := f.ToMap()
 	if err != nil {
-		return nil, UnauthorizedError{fmt.Sprintf("trip.repository: failed to create request (%s)", err)}
+		return nil, err
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", r.authToken))
+	params[OffsetString] = strconv.Itoa(page.Offset)
+	params[LimitString] = strconv.Itoa(page.Limit)
 
-	q := req.URL.Query()
+	var lastErr error
+	for attempt := 0; attempt <= r.conf.MaxRetries; attempt++ {
+		if attempt > 0 {
+			err := r.wait(ctx, attempt)
+			if err != nil {
+				return nil, err
+			}
+		}
 
-	params, err := f.ToMap()
-	for key, value := range params {
-		if key != LeaveAtString && key != ArriveByString {
-			q.Set(key, value)
+		if !r.breaker.allow() {
+			return nil, UnavailableError{"trip.repository: trip service is unavailable (circuit breaker is open)"}
 		}
+
+		trips, retry, err := r.find(ctx, params)
+		if err == nil {
+			r.breaker.success()
+			return trips, nil
+		}
+
+		if !retry {
+			// The trip service is not to blame for the errors that are not
+			// worth retrying, like a client error, but the outcome must be
+			// recorded so that a trial call does not keep the breaker open.
+			if ctx.Err() != nil {
+				r.breaker.abandon()
+			} else {
+				r.breaker.success()
+			}
+
+			return nil, err
+		}
+
+		r.breaker.failure()
+		lastErr = err
+
+		r.logger.WarnContext(ctx, "request to trip service failed", "attempt", attempt+1, "error", err)
 	}
 
-	req.URL.RawQuery = q.Encode()
+	return nil, UnavailableError{fmt.Sprintf("trip.repository: trip service is unavailable after %d attempts (%s)", r.conf.MaxRetries+1, lastErr)}
+}
+
+// find makes a single request to the trip service and returns whether it is
+// worth retrying when it fails. The trace context is sent along with the
+// request, so that the trip service can continue the trace.
+func (r *RestRepository) find(ctx context.Context, params map[string]string) (_ []*entity.Trip, _ bool, err error) {
+	ctx, span := tracer.Start(ctx, "GET /trips", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { tracing.End(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://"+r.domain+"/trips", nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("trip.repository: failed to create request (%s)", err)
+	}
 
-	if len(params[LeaveAtString]) > 0 {
-		req.URL.RawQuery = fmt.Sprintf("%s%s%s", req.URL.RawQuery, "&"+LeaveAtString+"=", params[LeaveAtString])
+	err = r.credentials.Apply(req)
+	if err != nil {
+		return nil, false, UnauthorizedError{fmt.Sprintf("trip.repository: failed to authenticate request (%s)", err)}
 	}
 
-	if len(params[ArriveByString]) > 0 {
-		req.URL.RawQuery = fmt.Sprintf("%s%s%s", req.URL.RawQuery, "&"+ArriveByString+"=", params[ArriveByString])
+	if requestID, err := requestid.FromContext(ctx); err == nil {
+		req.Header.Set("X-Request-ID", requestID)
 	}
 
-	fmt.Printf(req.URL.RawQuery)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	q := req.URL.Query()
+
+	for key, value := range params {
+		q.Set(key, value)
+	}
+
+	req.URL.RawQuery = q.Encode()
+
+	r.logger.DebugContext(ctx, "requesting trips", "query", req.URL.RawQuery)
+
+	start := time.Now()
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := r.client.Do(req)
 	if err != nil {
-		return nil, err
+		metrics.TripServiceDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
+		return nil, ctx.Err() == nil, fmt.Errorf("trip.repository: failed request to trip-api (%s)", err)
 	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	metrics.TripServiceDuration.WithLabelValues(strconv.Itoa(resp.StatusCode)).Observe(time.Since(start).Seconds())
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("trip.repository: failed request to trip-api (HTTP %d)", resp.StatusCode)
+		retry := resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf("trip.repository: failed request to trip-api (HTTP %d)", resp.StatusCode)
 	}
 
 	var trips []*entity.Trip
 	err = json.NewDecoder(resp.Body).Decode(&trips)
 	if err != nil {
-		return nil, fmt.Errorf("trip.repository: failed to decode trips (%s)", err)
+		return nil, false, fmt.Errorf("trip.repository: failed to decode trips (%s)", err)
+	}
+
+	return trips, false, nil
+}
+
+// Ping checks that the trip service can be reached. Any response other than a
+// server error means that it is up, even when the request is not authorized,
+// since the probe is not authenticated.
+//
+// The probe does not count toward the circuit breaker, but the trip service is
+// reported as unavailable while the breaker is open.
+func (r *RestRepository) Ping(ctx context.Context) error {
+	if r.breaker.isOpen() {
+		return UnavailableError{"trip.repository: trip service is unavailable (circuit breaker is open)"}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://"+r.domain+"/trips", nil)
+	if err != nil {
+		return fmt.Errorf("trip.repository: failed to create request (%s)", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return UnavailableError{fmt.Sprintf("trip.repository: failed request to trip-api (%s)", err)}
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return UnavailableError{fmt.Sprintf("trip.repository: failed request to trip-api (HTTP %d)", resp.StatusCode)}
+	}
+
+	return nil
+}
+
+// wait blocks for the jittered backoff delay of the given retry attempt, or
+// until the context is done.
+func (r *RestRepository) wait(ctx context.Context, attempt int) error {
+	delay := r.conf.RetryBaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > r.conf.RetryMaxDelay {
+		delay = r.conf.RetryMaxDelay
+	}
+	delay = time.Duration(rand.Int63n(int64(delay)) + 1)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	return trips, nil
 }
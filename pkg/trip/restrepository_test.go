@@ -0,0 +1,296 @@
+package trip
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestRestRepository(t *testing.T, server *httptest.Server, conf *ClientConfig) Repository {
+	credentials, err := NewBasicCredentials("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Transport = server.Client().Transport
+	conf.RetryBaseDelay = time.Millisecond
+	conf.RetryMaxDelay = time.Millisecond
+
+	repo, err := NewRestRepository(strings.TrimPrefix(server.URL, "https://"), credentials, conf, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repo
+}
+
+func newTestFilters() *entity.Filters {
+	return &entity.Filters{
+		Source:      &entity.Point{Latitude: 45.50, Longitude: -73.56},
+		Destination: &entity.Point{Latitude: 46.81, Longitude: -71.20},
+		LeaveAt:     time.Now(),
+	}
+}
+
+func TestRestRepositoryFind(t *testing.T) {
+	t.Run("Should retry server errors until the request succeeds", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.Write([]byte(`[{"id":"1"},{"id":"2"}]`))
+		}))
+		defer server.Close()
+
+		repo := newTestRestRepository(t, server, &ClientConfig{MaxRetries: 2})
+
+		trips, err := repo.Find(context.Background(), newTestFilters(), Page{Limit: DefaultPageSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(trips) != 2 {
+			t.Errorf("expected 2 trips, got %d", len(trips))
+		}
+	})
+
+	t.Run("Should send the times of the filters escaped", func(t *testing.T) {
+		f := newTestFilters()
+		f.LeaveAt = time.Date(2026, time.October, 19, 7, 30, 0, 0, time.FixedZone("", 2*60*60))
+		f.LeaveBefore = f.LeaveAt.Add(time.Hour)
+
+		query := make(chan url.Values, 1)
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query <- r.URL.Query()
+			w.Write([]byte(`[]`))
+		}))
+		defer server.Close()
+
+		repo := newTestRestRepository(t, server, &ClientConfig{})
+
+		_, err := repo.Find(context.Background(), f, Page{Limit: DefaultPageSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q := <-query
+		for key, expected := range map[string]time.Time{LeaveAtString: f.LeaveAt, LeaveBeforeString: f.LeaveBefore} {
+			if q.Get(key) != expected.Format(entity.FormatTimeRgx) {
+				t.Errorf("expected %s to be \"%s\", got \"%s\"", key, expected.Format(entity.FormatTimeRgx), q.Get(key))
+			}
+		}
+	})
+
+	t.Run("Should not retry client errors", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		repo := newTestRestRepository(t, server, &ClientConfig{MaxRetries: 2})
+
+		_, err := repo.Find(context.Background(), newTestFilters(), Page{Limit: DefaultPageSize})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if requests != 1 {
+			t.Errorf("expected 1 request, got %d", requests)
+		}
+	})
+
+	t.Run("Should give up when a request takes longer than the timeout", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		}))
+		defer server.Close()
+
+		repo := newTestRestRepository(t, server, &ClientConfig{Timeout: 10 * time.Millisecond})
+
+		_, err := repo.Find(context.Background(), newTestFilters(), Page{Limit: DefaultPageSize})
+		if _, ok := err.(UnavailableError); !ok {
+			t.Errorf("expected an UnavailableError, got %v", err)
+		}
+	})
+
+	t.Run("Should stop making requests once the circuit breaker opens", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		repo := newTestRestRepository(t, server, &ClientConfig{
+			MaxRetries:       1,
+			BreakerThreshold: 2,
+			BreakerCooldown:  time.Hour})
+
+		for i := 0; i < 3; i++ {
+			_, err := repo.Find(context.Background(), newTestFilters(), Page{Limit: DefaultPageSize})
+			if _, ok := err.(UnavailableError); !ok {
+				t.Errorf("expected an UnavailableError, got %v", err)
+			}
+		}
+
+		if requests != 2 {
+			t.Errorf("expected 2 requests, got %d", requests)
+		}
+	})
+
+	t.Run("Should let a trial request through once the cooldown has elapsed", func(t *testing.T) {
+		var healthy int32
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&healthy) == 0 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			w.Write([]byte(`[]`))
+		}))
+		defer server.Close()
+
+		repo := newTestRestRepository(t, server, &ClientConfig{
+			BreakerThreshold: 1,
+			BreakerCooldown:  10 * time.Millisecond})
+
+		_, err := repo.Find(context.Background(), newTestFilters(), Page{Limit: DefaultPageSize})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		atomic.StoreInt32(&healthy, 1)
+		time.Sleep(20 * time.Millisecond)
+
+		_, err = repo.Find(context.Background(), newTestFilters(), Page{Limit: DefaultPageSize})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Should close the circuit breaker when the trial request is rejected", func(t *testing.T) {
+		var status int32 = http.StatusInternalServerError
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s := atomic.LoadInt32(&status); s != http.StatusOK {
+				w.WriteHeader(int(s))
+				return
+			}
+
+			w.Write([]byte(`[]`))
+		}))
+		defer server.Close()
+
+		repo := newTestRestRepository(t, server, &ClientConfig{
+			BreakerThreshold: 1,
+			BreakerCooldown:  10 * time.Millisecond})
+
+		_, err := repo.Find(context.Background(), newTestFilters(), Page{Limit: DefaultPageSize})
+		if _, ok := err.(UnavailableError); !ok {
+			t.Fatalf("expected an UnavailableError, got %v", err)
+		}
+
+		atomic.StoreInt32(&status, http.StatusBadRequest)
+		time.Sleep(20 * time.Millisecond)
+
+		_, err = repo.Find(context.Background(), newTestFilters(), Page{Limit: DefaultPageSize})
+		if _, ok := err.(UnavailableError); err == nil || ok {
+			t.Fatalf("expected the trial request to be rejected, got %v", err)
+		}
+
+		atomic.StoreInt32(&status, http.StatusOK)
+
+		_, err = repo.Find(context.Background(), newTestFilters(), Page{Limit: DefaultPageSize})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestRestRepositoryTracing(t *testing.T) {
+	t.Run("Should propagate the trace context to the trip service", func(t *testing.T) {
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+
+		var traceparent atomic.Value
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent.Store(r.Header.Get("traceparent"))
+			w.Write([]byte(`[]`))
+		}))
+		defer server.Close()
+
+		repo := newTestRestRepository(t, server, &ClientConfig{})
+
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+		}))
+
+		_, err := repo.Find(ctx, newTestFilters(), Page{Limit: DefaultPageSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		header, _ := traceparent.Load().(string)
+		if !strings.Contains(header, traceID.String()) {
+			t.Errorf("expected traceparent with trace ID %s, got %q", traceID, header)
+		}
+	})
+}
+
+func TestRestRepositoryPing(t *testing.T) {
+	t.Run("Should be up when the trip service rejects the probe", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		repo := newTestRestRepository(t, server, &ClientConfig{})
+
+		err := repo.Ping(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Should be down while the circuit breaker is open", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		repo := newTestRestRepository(t, server, &ClientConfig{
+			BreakerThreshold: 1,
+			BreakerCooldown:  time.Minute})
+
+		_, _ = repo.Find(context.Background(), newTestFilters(), Page{Limit: DefaultPageSize})
+
+		err := repo.Ping(context.Background())
+		if _, ok := err.(UnavailableError); !ok {
+			t.Errorf("expected an UnavailableError, got %v", err)
+		}
+
+		if requests != 1 {
+			t.Errorf("expected 1 request, got %d", requests)
+		}
+	})
+}
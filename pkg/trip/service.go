@@ -1,37 +1,84 @@
 package trip
 
 import (
+	"context"
+
 	"azure.com/ecovo/trip-search-service/pkg/entity"
 )
 
+// A PageHandler is a function that handles a page of trips as soon as it is
+// retrieved. Returning an error stops the retrieval of the following pages.
+type PageHandler func(trips []*entity.Trip) error
+
 // UseCase is an interface representing the ability to handle the business
 // logic that involves trips.
 type UseCase interface {
-	Find(filters *entity.Filters) ([]*entity.Trip, error)
+	Find(ctx context.Context, filters *entity.Filters, handle PageHandler) error
 }
 
 // A Service handles the business logic related to trips.
 type Service struct {
-	repo Repository
+	repo     Repository
+	pageSize int
 }
 
+// DefaultPageSize represents the default number of trips retrieved at once.
+const DefaultPageSize = 50
+
+// maxPages represents how many pages are retrieved at most, in case the
+// repository keeps returning full pages.
+const maxPages = 200
+
 // NewService creates a trip service to handle business logic and manipulate
 // trips through a repository.
 func NewService(repo Repository) *Service {
-	return &Service{repo}
+	return &Service{repo, DefaultPageSize}
 }
 
-// Find retrieves all the trips
-func (s *Service) Find(filters *entity.Filters) ([]*entity.Trip, error) {
+// Find retrieves all the trips matching the filters, one page at a time, and
+// hands each page to the given handler as soon as it is retrieved.
+//
+// The trips that were already handed over are left out of the following pages,
+// and the retrieval stops at the first full page that has no new trip, in case
+// the repository ignores the offset and returns the same page every time.
+func (s *Service) Find(ctx context.Context, filters *entity.Filters, handle PageHandler) error {
 	err := filters.Validate()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	t, err := s.repo.Find(filters)
-	if err != nil {
-		return []*entity.Trip{}, err
+	seen := make(map[entity.ID]bool)
+	page := Page{Offset: 0, Limit: s.pageSize}
+	for i := 0; i < maxPages; i++ {
+		t, err := s.repo.Find(ctx, filters, page)
+		if err != nil {
+			return err
+		}
+
+		fresh := make([]*entity.Trip, 0, len(t))
+		for _, trip := range t {
+			if trip.ID.IsZero() || !seen[trip.ID] {
+				seen[trip.ID] = true
+				fresh = append(fresh, trip)
+			}
+		}
+
+		if len(fresh) > 0 {
+			err = handle(fresh)
+			if err != nil {
+				return err
+			}
+		}
+
+		// A short page means there are no more trips, and a page that is too
+		// long means the repository doesn't paginate, so everything was
+		// retrieved at once.
+		if len(t) != page.Limit || len(fresh) == 0 {
+			return nil
+		}
+
+		page.Offset += len(t)
 	}
 
-	return t, nil
+	return nil
 }
@@ -0,0 +1,122 @@
+package trip
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/entity"
+)
+
+type pagingRepository struct {
+	trips []*entity.Trip
+	pages []Page
+}
+
+func (r *pagingRepository) Find(ctx context.Context, f *entity.Filters, page Page) ([]*entity.Trip, error) {
+	r.pages = append(r.pages, page)
+
+	if page.Offset >= len(r.trips) {
+		return nil, nil
+	}
+
+	end := page.Offset + page.Limit
+	if end > len(r.trips) {
+		end = len(r.trips)
+	}
+
+	return r.trips[page.Offset:end], nil
+}
+
+func (r *pagingRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func TestServiceFind(t *testing.T) {
+	filters := &entity.Filters{
+		Source:      &entity.Point{Latitude: 45.50, Longitude: -73.56},
+		Destination: &entity.Point{Latitude: 46.81, Longitude: -71.20},
+		LeaveAt:     time.Now(),
+	}
+
+	t.Run("Should page through all the trips", func(t *testing.T) {
+		repo := &pagingRepository{}
+		for i := 0; i < 5; i++ {
+			repo.trips = append(repo.trips, &entity.Trip{})
+		}
+
+		s := &Service{repo, 2}
+
+		var found int
+		err := s.Find(context.Background(), filters, func(trips []*entity.Trip) error {
+			found += len(trips)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if found != 5 {
+			t.Errorf("expected 5 trips, got %d", found)
+		}
+
+		if len(repo.pages) != 3 || repo.pages[2].Offset != 4 {
+			t.Errorf("unexpected pages %v", repo.pages)
+		}
+	})
+
+	t.Run("Should request one more page when the last one is full", func(t *testing.T) {
+		repo := &pagingRepository{trips: []*entity.Trip{{}, {}}}
+
+		s := &Service{repo, 2}
+
+		err := s.Find(context.Background(), filters, func(trips []*entity.Trip) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(repo.pages) != 2 {
+			t.Errorf("expected 2 pages, got %d", len(repo.pages))
+		}
+	})
+
+	t.Run("Should stop when the repository does not paginate and returns full pages", func(t *testing.T) {
+		repo := &staticRepository{trips: []*entity.Trip{{ID: "1"}, {ID: "2"}}}
+
+		s := &Service{repo, 2}
+
+		var found int
+		err := s.Find(context.Background(), filters, func(trips []*entity.Trip) error {
+			found += len(trips)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if found != 2 {
+			t.Errorf("expected 2 trips, got %d", found)
+		}
+
+		if repo.requests != 2 {
+			t.Errorf("expected 2 requests, got %d", repo.requests)
+		}
+	})
+}
+
+// A staticRepository ignores the page and always returns the same trips.
+type staticRepository struct {
+	trips    []*entity.Trip
+	requests int
+}
+
+func (r *staticRepository) Find(ctx context.Context, f *entity.Filters, page Page) ([]*entity.Trip, error) {
+	r.requests++
+	return r.trips, nil
+}
+
+func (r *staticRepository) Ping(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,195 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultMaxConcurrentDeliveries represents how many events a dispatcher
+// delivers at once by default.
+const DefaultMaxConcurrentDeliveries = 32
+
+// maxPendingDeliveries represents how many events can wait to be delivered
+// for each event delivered at once.
+const maxPendingDeliveries = 32
+
+// A Dispatcher delivers events in the background and logs their outcome, so
+// that whatever produces the events is not held back by slow receivers. The
+// events are not necessarily delivered in the order they were dispatched.
+//
+// The events wait in a bounded queue to be delivered by a fixed number of
+// goroutines. The events dispatched while the queue is full are dropped, and
+// logged as such, rather than holding back whatever produces them.
+type Dispatcher struct {
+	client *Client
+	log    DeliveryLog
+	logger *slog.Logger
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	queue  chan *dispatch
+	closed bool
+
+	// ctx is done once the dispatcher gives up on the pending deliveries.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// A dispatch is an event waiting to be delivered.
+type dispatch struct {
+	ctx     context.Context
+	subject string
+	url     string
+	secret  string
+	event   string
+	payload interface{}
+}
+
+// NewDispatcher creates a dispatcher that delivers events with the client and
+// keeps their outcome in the log. At most maxConcurrent events are delivered
+// at once, or DefaultMaxConcurrentDeliveries when it is zero.
+func NewDispatcher(client *Client, log DeliveryLog, maxConcurrent int, logger *slog.Logger) (*Dispatcher, error) {
+	if client == nil {
+		return nil, fmt.Errorf("webhook.Dispatcher: client is nil")
+	}
+
+	if log == nil {
+		return nil, fmt.Errorf("webhook.Dispatcher: delivery log is nil")
+	}
+
+	if logger == nil {
+		return nil, fmt.Errorf("webhook.Dispatcher: logger is nil")
+	}
+
+	if maxConcurrent < 0 {
+		return nil, fmt.Errorf("webhook.Dispatcher: max concurrent deliveries cannot be negative")
+	} else if maxConcurrent == 0 {
+		maxConcurrent = DefaultMaxConcurrentDeliveries
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := &Dispatcher{
+		client: client,
+		log:    log,
+		logger: logger,
+		queue:  make(chan *dispatch, maxConcurrent*maxPendingDeliveries),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	d.wg.Add(maxConcurrent)
+	for i := 0; i < maxConcurrent; i++ {
+		go func() {
+			defer d.wg.Done()
+
+			for e := range d.queue {
+				d.deliver(e)
+			}
+		}()
+	}
+
+	return d, nil
+}
+
+// Dispatch queues the event to be delivered in the background, and its outcome
+// to be appended to the log under the subject. The delivery keeps the
+// context's values, but outlives it, so that it can be dispatched while
+// handling something short lived.
+//
+// The event is dropped when the queue is full or the dispatcher was shut down.
+func (d *Dispatcher) Dispatch(ctx context.Context, subject string, url string, secret string, event string, payload interface{}) {
+	e := &dispatch{context.WithoutCancel(ctx), subject, url, secret, event, payload}
+
+	var reason string
+
+	d.mu.RLock()
+	if d.closed {
+		reason = "the dispatcher is shut down"
+	} else {
+		select {
+		case d.queue <- e:
+		default:
+			reason = "too many events are waiting to be delivered"
+		}
+	}
+	d.mu.RUnlock()
+
+	if reason != "" {
+		d.drop(e, reason)
+	}
+}
+
+// drop logs that the event was not delivered, without trying to.
+func (d *Dispatcher) drop(e *dispatch, reason string) {
+	d.logger.WarnContext(e.ctx, "dropped event for webhook", "event", e.event, "reason", reason)
+
+	now := time.Now().UTC()
+	err := d.log.Append(e.ctx, e.subject, &Delivery{
+		Event:     e.event,
+		URL:       e.url,
+		Error:     "webhook.Dispatcher: dropped event (" + reason + ")",
+		StartedAt: now,
+		EndedAt:   now,
+	})
+	if err != nil {
+		d.logger.WarnContext(e.ctx, "failed to log webhook delivery", "error", err)
+	}
+}
+
+// deliver delivers the event and logs its outcome.
+func (d *Dispatcher) deliver(e *dispatch) {
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+	stop := context.AfterFunc(d.ctx, cancel)
+	defer stop()
+
+	delivery, err := d.client.Send(ctx, e.url, e.secret, e.event, e.payload)
+	if err != nil {
+		d.logger.WarnContext(ctx, "failed to deliver event to webhook", "event", e.event, "attempts", delivery.Attempts, "error", err)
+	}
+
+	// The outcome is logged even when the delivery was abandoned, which is
+	// when it matters most.
+	err = d.log.Append(e.ctx, e.subject, delivery)
+	if err != nil {
+		d.logger.WarnContext(ctx, "failed to log webhook delivery", "error", err)
+	}
+}
+
+// Deliveries retrieves the latest deliveries about the subject, from the most
+// recent, up to the limit.
+func (d *Dispatcher) Deliveries(ctx context.Context, subject string, limit int) ([]*Delivery, error) {
+	return d.log.FindBySubject(ctx, subject, limit)
+}
+
+// Shutdown stops accepting events and waits for the pending deliveries until
+// the context is done, and then gives up on the ones that are left.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	d.cancel()
+	<-done
+
+	return fmt.Errorf("webhook.Dispatcher: gave up on pending deliveries (%s)", ctx.Err())
+}
@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DeliveryLog is an interface representing the ability to keep the outcome
+// of deliveries, grouped by what they are about, like a search.
+type DeliveryLog interface {
+	Append(ctx context.Context, subject string, d *Delivery) error
+
+	// FindBySubject retrieves the latest deliveries about the subject, from
+	// the most recent, up to the limit.
+	FindBySubject(ctx context.Context, subject string, limit int) ([]*Delivery, error)
+}
+
+const (
+	// maxMemoryDeliveries represents how many deliveries a MemoryDeliveryLog
+	// keeps for each subject.
+	maxMemoryDeliveries = 100
+
+	// memoryDeliveryTTL represents how long a MemoryDeliveryLog keeps a
+	// delivery once it ended, like the database does.
+	memoryDeliveryTTL = 7 * 24 * time.Hour
+
+	// memoryPruneInterval represents how often a MemoryDeliveryLog forgets
+	// the deliveries that expired.
+	memoryPruneInterval = time.Hour
+)
+
+// A MemoryDeliveryLog is a delivery log that keeps the latest deliveries of
+// each subject in memory, for memoryDeliveryTTL.
+//
+// It is safe for concurrent use, but the deliveries are lost when the service
+// stops and are not shared with other instances.
+type MemoryDeliveryLog struct {
+	mu         sync.Mutex
+	deliveries map[string][]Delivery
+	prunedAt   time.Time
+}
+
+// NewMemoryDeliveryLog creates an empty in-memory delivery log.
+func NewMemoryDeliveryLog() (DeliveryLog, error) {
+	return &MemoryDeliveryLog{deliveries: make(map[string][]Delivery)}, nil
+}
+
+// Append keeps a copy of the delivery, forgetting the oldest one of the
+// subject when there are too many.
+func (l *MemoryDeliveryLog) Append(ctx context.Context, subject string, d *Delivery) error {
+	if d == nil {
+		return fmt.Errorf("webhook.MemoryDeliveryLog: failed to append delivery (delivery is nil)")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	deliveries := append(l.deliveries[subject], *d)
+	if len(deliveries) > maxMemoryDeliveries {
+		deliveries = deliveries[len(deliveries)-maxMemoryDeliveries:]
+	}
+	l.deliveries[subject] = deliveries
+
+	if now := time.Now(); now.Sub(l.prunedAt) >= memoryPruneInterval {
+		l.prune(now)
+	}
+
+	return nil
+}
+
+// prune forgets the deliveries that expired, along with the subjects that have
+// none left, such as the searches that ended a while ago.
+func (l *MemoryDeliveryLog) prune(now time.Time) {
+	for subject, deliveries := range l.deliveries {
+		kept := deliveries[:0]
+		for _, d := range deliveries {
+			if !expired(&d, now) {
+				kept = append(kept, d)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(l.deliveries, subject)
+		} else {
+			l.deliveries[subject] = kept
+		}
+	}
+
+	l.prunedAt = now
+}
+
+// expired returns whether the delivery ended more than memoryDeliveryTTL ago.
+func expired(d *Delivery, now time.Time) bool {
+	return now.Sub(d.EndedAt) > memoryDeliveryTTL
+}
+
+// FindBySubject retrieves the latest deliveries about the subject, from the
+// most recent, up to the limit.
+func (l *MemoryDeliveryLog) FindBySubject(ctx context.Context, subject string, limit int) ([]*Delivery, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	deliveries := l.deliveries[subject]
+	now := time.Now()
+
+	found := []*Delivery{}
+	for i := len(deliveries) - 1; i >= 0 && len(found) < limit; i-- {
+		d := deliveries[i]
+		if !expired(&d, now) {
+			found = append(found, &d)
+		}
+	}
+
+	return found, nil
+}
@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/tracing"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/mongodb/mongo-go-driver/bson/primitive"
+	"github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("azure.com/ecovo/trip-search-service/pkg/webhook")
+
+// A MongoDeliveryLog is a delivery log that appends deliveries to a MongoDB
+// collection.
+//
+// The collection is expected to expire the documents some time after their
+// endedAt, so the log does not grow forever.
+type MongoDeliveryLog struct {
+	collection *mongo.Collection
+}
+
+type deliveryDocument struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Subject    string             `bson:"subject"`
+	Event      string             `bson:"event"`
+	URL        string             `bson:"url"`
+	Attempts   int                `bson:"attempts"`
+	Delivered  bool               `bson:"delivered"`
+	StatusCode int                `bson:"statusCode,omitempty"`
+	Error      string             `bson:"error,omitempty"`
+	StartedAt  time.Time          `bson:"startedAt"`
+	EndedAt    time.Time          `bson:"endedAt"`
+}
+
+func (d deliveryDocument) Entity() *Delivery {
+	return &Delivery{
+		Event:      d.Event,
+		URL:        d.URL,
+		Attempts:   d.Attempts,
+		Delivered:  d.Delivered,
+		StatusCode: d.StatusCode,
+		Error:      d.Error,
+		StartedAt:  d.StartedAt,
+		EndedAt:    d.EndedAt,
+	}
+}
+
+// operationTimeout represents how long to wait for the database server to
+// complete an operation before giving up on it.
+const operationTimeout = 5 * time.Second
+
+// startOperation gives the database server operationTimeout to complete an
+// operation, and traces it. The returned function must be called with the
+// operation's error once it is done.
+func (l *MongoDeliveryLog) startOperation(ctx context.Context, operation string) (context.Context, func(error)) {
+	ctx, span := tracer.Start(ctx, "mongo."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemMongoDB,
+		semconv.DBCollectionName(l.collection.Name()),
+		semconv.DBOperationName(operation),
+	))
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+
+	return ctx, func(err error) {
+		cancel()
+		tracing.End(span, err)
+	}
+}
+
+// NewMongoDeliveryLog creates a delivery log for a MongoDB collection.
+func NewMongoDeliveryLog(collection *mongo.Collection) (DeliveryLog, error) {
+	if collection == nil {
+		return nil, fmt.Errorf("webhook.MongoDeliveryLog: collection is nil")
+	}
+
+	return &MongoDeliveryLog{collection}, nil
+}
+
+// Append stores the delivery in the database.
+func (l *MongoDeliveryLog) Append(ctx context.Context, subject string, d *Delivery) (err error) {
+	if d == nil {
+		return fmt.Errorf("webhook.MongoDeliveryLog: failed to append delivery (delivery is nil)")
+	}
+
+	ctx, done := l.startOperation(ctx, "insertOne")
+	defer func() { done(err) }()
+
+	_, err = l.collection.InsertOne(ctx, &deliveryDocument{
+		Subject:    subject,
+		Event:      d.Event,
+		URL:        d.URL,
+		Attempts:   d.Attempts,
+		Delivered:  d.Delivered,
+		StatusCode: d.StatusCode,
+		Error:      d.Error,
+		StartedAt:  d.StartedAt,
+		EndedAt:    d.EndedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("webhook.MongoDeliveryLog: failed to append delivery about \"%s\" (%s)", subject, err)
+	}
+
+	return nil
+}
+
+// FindBySubject retrieves the latest deliveries about the subject, from the
+// most recent, up to the limit.
+func (l *MongoDeliveryLog) FindBySubject(ctx context.Context, subject string, limit int) (_ []*Delivery, err error) {
+	ctx, done := l.startOperation(ctx, "find")
+	defer func() { done(err) }()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "endedAt", Value: -1}}).
+		SetLimit(int64(limit))
+	cur, err := l.collection.Find(ctx, bson.D{{Key: "subject", Value: subject}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("webhook.MongoDeliveryLog: failed to find deliveries about \"%s\" (%s)", subject, err)
+	}
+	defer cur.Close(ctx)
+
+	deliveries := []*Delivery{}
+	for cur.Next(ctx) {
+		var d deliveryDocument
+		err := cur.Decode(&d)
+		if err != nil {
+			return nil, fmt.Errorf("webhook.MongoDeliveryLog: failed to decode delivery (%s)", err)
+		}
+
+		deliveries = append(deliveries, d.Entity())
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("webhook.MongoDeliveryLog: failed to find deliveries about \"%s\" (%s)", subject, err)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateNetwork is returned when an event would be delivered to a private,
+// loopback or link-local address, like the ones of the services next to the
+// service or of the cloud metadata endpoint.
+var ErrPrivateNetwork = errors.New("destination is on a private network")
+
+// isPrivate returns whether the IP address belongs to a private, loopback or
+// link-local network, or is unspecified.
+func isPrivate(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// rejectPrivate is called once the address to connect to is resolved, so that
+// a host name cannot resolve to a private address after it was checked.
+func rejectPrivate(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || isPrivate(ip) {
+		return fmt.Errorf("%w (%s)", ErrPrivateNetwork, host)
+	}
+
+	return nil
+}
+
+// publicTransport returns a transport like http.DefaultTransport that refuses
+// to connect to private, loopback or link-local addresses.
+func publicTransport() http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   rejectPrivate,
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = dialer.DialContext
+	// The events are not sent through a proxy, since the address it connects
+	// to could not be checked.
+	t.Proxy = nil
+
+	return t
+}
@@ -0,0 +1,285 @@
+// Package webhook delivers events to HTTP endpoints as JSON. The bodies are
+// signed with a secret shared with the receiver, so that it can check that
+// they come from the service.
+//
+// Failed deliveries are retried with a jittered exponential backoff, and the
+// outcome of each delivery can be kept in a log.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// EventHeader represents the header that contains the type of the event
+	// being delivered.
+	EventHeader = "X-Event"
+
+	// SignatureHeader represents the header that contains the signature of
+	// the body, as "sha256=" followed by the hex-encoded HMAC-SHA256 of the
+	// body keyed with the secret. It is only sent when there is a secret.
+	SignatureHeader = "X-Signature-256"
+)
+
+// ClientConfig contains the information required to configure how events are
+// delivered.
+type ClientConfig struct {
+	// Timeout specifies how long to wait for the receiver to respond to a
+	// single attempt.
+	//
+	// A timeout of zero means DefaultTimeout.
+	Timeout time.Duration
+
+	// MaxRetries specifies how many times a failed delivery is retried before
+	// giving up. Only network errors, server errors and 429 Too Many Requests
+	// are retried.
+	MaxRetries int
+
+	// RetryBaseDelay specifies how long to wait before the first retry. The
+	// delay doubles for each subsequent retry, and a random jitter is applied
+	// to it.
+	//
+	// A delay of zero means DefaultRetryBaseDelay.
+	RetryBaseDelay time.Duration
+
+	// RetryMaxDelay specifies the longest delay to wait between two retries.
+	//
+	// A delay of zero means DefaultRetryMaxDelay.
+	RetryMaxDelay time.Duration
+
+	// AllowPrivateNetworks specifies whether events can be delivered to
+	// private, loopback and link-local addresses. They are refused by
+	// default, since the URLs may be given by anyone who starts a search.
+	AllowPrivateNetworks bool
+
+	// Transport specifies the mechanism by which requests are made. It is
+	// optional and defaults to a transport like http.DefaultTransport, that
+	// refuses to connect to private networks unless they are allowed. The
+	// networks a given transport connects to are not checked.
+	Transport http.RoundTripper
+}
+
+const (
+	// DefaultTimeout represents the default amount of time to wait for a
+	// receiver to respond.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxRetries represents the default number of times a failed
+	// delivery is retried.
+	DefaultMaxRetries = 3
+
+	// DefaultRetryBaseDelay represents the default amount of time to wait
+	// before the first retry.
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+
+	// DefaultRetryMaxDelay represents the default longest amount of time to
+	// wait between two retries.
+	DefaultRetryMaxDelay = 10 * time.Second
+)
+
+// DefaultClientConfig returns the configuration used when none is given.
+func DefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		Timeout:        DefaultTimeout,
+		MaxRetries:     DefaultMaxRetries,
+		RetryBaseDelay: DefaultRetryBaseDelay,
+		RetryMaxDelay:  DefaultRetryMaxDelay,
+	}
+}
+
+// A Delivery describes the outcome of delivering an event.
+type Delivery struct {
+	// Event represents the type of the event that was delivered.
+	Event string `json:"event"`
+
+	// URL represents where the event was delivered.
+	URL string `json:"url"`
+
+	// Attempts represents how many requests were made.
+	Attempts int `json:"attempts"`
+
+	// Delivered represents whether the receiver accepted the event.
+	Delivered bool `json:"delivered"`
+
+	// StatusCode represents the status of the receiver's last response. It
+	// is zero when the receiver could not be reached.
+	StatusCode int `json:"statusCode,omitempty"`
+
+	// Error represents why the last attempt failed, if it did.
+	Error string `json:"error,omitempty"`
+
+	// StartedAt and EndedAt represent when the first attempt started and
+	// when the last one ended.
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+}
+
+// A Client delivers events to webhooks.
+type Client struct {
+	conf   *ClientConfig
+	client *http.Client
+}
+
+// NewClient creates a client to deliver events to webhooks with the given
+// configuration, or the default one when it is nil.
+func NewClient(conf *ClientConfig) (*Client, error) {
+	if conf == nil {
+		conf = DefaultClientConfig()
+	}
+
+	if conf.Timeout < 0 {
+		return nil, fmt.Errorf("webhook.Client: timeout cannot be negative")
+	}
+
+	if conf.MaxRetries < 0 {
+		return nil, fmt.Errorf("webhook.Client: max retries cannot be negative")
+	}
+
+	c := *conf
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.RetryBaseDelay == 0 {
+		c.RetryBaseDelay = DefaultRetryBaseDelay
+	}
+	if c.RetryMaxDelay == 0 {
+		c.RetryMaxDelay = DefaultRetryMaxDelay
+	}
+
+	if c.Transport == nil && !c.AllowPrivateNetworks {
+		c.Transport = publicTransport()
+	}
+
+	return &Client{
+		conf:   &c,
+		client: &http.Client{Timeout: c.Timeout, Transport: c.Transport},
+	}, nil
+}
+
+// Sign returns the signature of the body keyed with the secret, as it is sent
+// in the SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns whether the signature is the one of the body keyed with the
+// secret. It takes the same time whatever the signature, so that it can be
+// used by receivers.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Send posts the event's payload as JSON to the URL, signed with the secret
+// when there is one, and returns the outcome of the delivery. Any response
+// other than a 2xx is an error.
+//
+// Failed attempts are retried with a jittered exponential backoff, until the
+// receiver accepts the event, the retries are exhausted or the context is
+// done. Every attempt sends the same body, so receivers can deduplicate them.
+func (c *Client) Send(ctx context.Context, url string, secret string, event string, payload interface{}) (*Delivery, error) {
+	d := &Delivery{Event: event, URL: url, StartedAt: time.Now().UTC()}
+
+	err := c.send(ctx, d, secret, payload)
+	d.EndedAt = time.Now().UTC()
+	if err != nil {
+		d.Error = err.Error()
+		return d, err
+	}
+
+	d.Delivered = true
+
+	return d, nil
+}
+
+func (c *Client) send(ctx context.Context, d *Delivery, secret string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("webhook.Client: failed to marshal payload (%s)", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			err := c.wait(ctx, attempt)
+			if err != nil {
+				return fmt.Errorf("webhook.Client: gave up sending event \"%s\" after %d attempts (%s)", d.Event, d.Attempts, err)
+			}
+		}
+
+		d.Attempts++
+
+		retry, err := c.attempt(ctx, d, secret, body)
+		if err == nil {
+			return nil
+		} else if !retry || attempt >= c.conf.MaxRetries {
+			return err
+		}
+	}
+}
+
+// attempt makes a single request to the receiver and returns whether it is
+// worth retrying when it fails.
+func (c *Client) attempt(ctx context.Context, d *Delivery, secret string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("webhook.Client: failed to create request (%s)", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.Event)
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		d.StatusCode = 0
+		retry := ctx.Err() == nil && !errors.Is(err, ErrPrivateNetwork)
+		return retry, fmt.Errorf("webhook.Client: failed to send event \"%s\" (%w)", d.Event, err)
+	}
+	defer res.Body.Close()
+
+	// The body is drained so that the connection can be reused.
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	d.StatusCode = res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		retry := res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook.Client: event \"%s\" was rejected with status %d", d.Event, res.StatusCode)
+	}
+
+	return false, nil
+}
+
+// wait blocks for the jittered backoff delay of the given retry attempt, or
+// until the context is done.
+func (c *Client) wait(ctx context.Context, attempt int) error {
+	delay := c.conf.RetryBaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > c.conf.RetryMaxDelay {
+		delay = c.conf.RetryMaxDelay
+	}
+	delay = time.Duration(rand.Int63n(int64(delay)) + 1)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
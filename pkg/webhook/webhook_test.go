@@ -0,0 +1,255 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"azure.com/ecovo/trip-search-service/pkg/logging"
+)
+
+func TestClientSend(t *testing.T) {
+	t.Run("Should post the payload signed with the secret", func(t *testing.T) {
+		var body []byte
+		var header http.Header
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ = ioutil.ReadAll(r.Body)
+			header = r.Header
+		}))
+		defer server.Close()
+
+		c, err := NewClient(&ClientConfig{MaxRetries: 2, RetryBaseDelay: time.Millisecond, AllowPrivateNetworks: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.Send(context.Background(), server.URL, "secret", "TEST", map[string]string{"hello": "world"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(body) != `{"hello":"world"}` {
+			t.Errorf("expected payload as JSON, got %s", body)
+		}
+		if header.Get(EventHeader) != "TEST" {
+			t.Errorf("expected event \"TEST\", got \"%s\"", header.Get(EventHeader))
+		}
+		if !Verify("secret", body, header.Get(SignatureHeader)) {
+			t.Errorf("expected a valid signature, got \"%s\"", header.Get(SignatureHeader))
+		}
+	})
+
+	t.Run("Should not sign the payload without a secret", func(t *testing.T) {
+		var signature string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			signature = r.Header.Get(SignatureHeader)
+		}))
+		defer server.Close()
+
+		c, err := NewClient(&ClientConfig{MaxRetries: 2, RetryBaseDelay: time.Millisecond, AllowPrivateNetworks: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.Send(context.Background(), server.URL, "", "TEST", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if signature != "" {
+			t.Errorf("expected no signature, got \"%s\"", signature)
+		}
+	})
+
+	t.Run("Should retry server errors until the receiver accepts the event", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+
+		c, err := NewClient(&ClientConfig{MaxRetries: 2, RetryBaseDelay: time.Millisecond, AllowPrivateNetworks: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := c.Send(context.Background(), server.URL, "", "TEST", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !d.Delivered || d.Attempts != 3 || d.StatusCode != http.StatusOK {
+			t.Errorf("expected delivery after 3 attempts, got %+v", d)
+		}
+	})
+
+	t.Run("Should not retry when the receiver rejects the event", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		c, err := NewClient(&ClientConfig{MaxRetries: 2, RetryBaseDelay: time.Millisecond, AllowPrivateNetworks: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := c.Send(context.Background(), server.URL, "", "TEST", nil)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if d.Delivered || d.Attempts != 1 || d.StatusCode != http.StatusBadRequest || requests != 1 {
+			t.Errorf("expected a single rejected attempt, got %+v", d)
+		}
+	})
+
+	t.Run("Should refuse to deliver to private networks by default", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+		}))
+		defer server.Close()
+
+		c, err := NewClient(&ClientConfig{MaxRetries: 2, RetryBaseDelay: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := c.Send(context.Background(), server.URL, "", "TEST", nil)
+		if !errors.Is(err, ErrPrivateNetwork) {
+			t.Fatalf("expected ErrPrivateNetwork, got %v", err)
+		}
+
+		if d.Attempts != 1 || requests != 0 {
+			t.Errorf("expected a single attempt that does not reach the receiver, got %+v", d)
+		}
+	})
+}
+
+func TestDispatcher(t *testing.T) {
+	t.Run("Should log the outcome of the deliveries from the most recent", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(EventHeader) == "REJECTED" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+		defer server.Close()
+
+		c, err := NewClient(&ClientConfig{RetryBaseDelay: time.Millisecond, AllowPrivateNetworks: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		log, err := NewMemoryDeliveryLog()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := NewDispatcher(c, log, 1, logging.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A single delivery at once keeps them in order.
+		ctx := context.Background()
+		for _, event := range []string{"ACCEPTED", "REJECTED"} {
+			d.Dispatch(ctx, "search", server.URL, "", event, nil)
+		}
+
+		err = d.Shutdown(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		deliveries, err := d.Deliveries(ctx, "search", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(deliveries) != 2 {
+			t.Fatalf("expected 2 deliveries, got %d", len(deliveries))
+		}
+		if deliveries[0].Event != "REJECTED" || deliveries[0].Delivered || deliveries[0].Error == "" {
+			t.Errorf("expected the rejected delivery first, got %+v", deliveries[0])
+		}
+		if deliveries[1].Event != "ACCEPTED" || !deliveries[1].Delivered {
+			t.Errorf("expected the accepted delivery last, got %+v", deliveries[1])
+		}
+	})
+	t.Run("Should drop the events dispatched once shut down", func(t *testing.T) {
+		c, err := NewClient(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		log, err := NewMemoryDeliveryLog()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := NewDispatcher(c, log, 1, logging.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.Background()
+		err = d.Shutdown(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d.Dispatch(ctx, "search", "https://example.com", "", "LATE", nil)
+
+		deliveries, err := d.Deliveries(ctx, "search", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(deliveries) != 1 || deliveries[0].Attempts != 0 || deliveries[0].Error == "" {
+			t.Errorf("expected a dropped delivery, got %+v", deliveries)
+		}
+	})
+}
+
+func TestMemoryDeliveryLog(t *testing.T) {
+	t.Run("Should forget the subjects whose deliveries expired", func(t *testing.T) {
+		log := &MemoryDeliveryLog{deliveries: make(map[string][]Delivery)}
+
+		old := time.Now().Add(-memoryDeliveryTTL - time.Minute)
+		err := log.Append(context.Background(), "ended", &Delivery{Event: "old", EndedAt: old})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		deliveries, err := log.FindBySubject(context.Background(), "ended", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 0 {
+			t.Errorf("expected the expired delivery to be hidden, got %+v", deliveries)
+		}
+
+		log.prunedAt = time.Time{}
+		err = log.Append(context.Background(), "active", &Delivery{Event: "new", EndedAt: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := log.deliveries["ended"]; ok {
+			t.Error("expected the subject with only expired deliveries to be forgotten")
+		}
+		if len(log.deliveries["active"]) != 1 {
+			t.Errorf("expected the recent delivery to be kept, got %+v", log.deliveries["active"])
+		}
+	})
+}